@@ -0,0 +1,95 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/thanm/grvutils/grparser"
+	"github.com/thanm/grvutils/zgr"
+)
+
+var verbflag = flag.Int("v", 0, "Verbose trace output level")
+var outfileflag = flag.String("o", "", "Output file for the textual report")
+var dotfileflag = flag.String("dot", "", "Write a merged DOT graph with additions in green and removals in red to this file")
+
+func verb(vlevel int, s string, a ...interface{}) {
+	if *verbflag >= vlevel {
+		fmt.Printf(s, a...)
+		fmt.Printf("\n")
+	}
+}
+
+func usage(msg string) {
+	if len(msg) > 0 {
+		fmt.Fprintf(os.Stderr, "error: %s\n", msg)
+	}
+	fmt.Fprintf(os.Stderr, "usage: grdiff [flags] old.graph new.graph\n")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func readGraph(path string) *zgr.Graph {
+	verb(1, "reading %s", path)
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	g := zgr.NewGraph()
+	if err := grparser.ParseGraph(f, g); err != nil {
+		log.Fatalf("%s: %v", path, err)
+	}
+	return g
+}
+
+func create(path string) *os.File {
+	verb(1, "opening %s", path)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return f
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("grdiff: ")
+	flag.Parse()
+	verb(1, "in main")
+	if flag.NArg() != 2 {
+		usage("expected two input files")
+	}
+	og := readGraph(flag.Arg(0))
+	ng := readGraph(flag.Arg(1))
+	d := og.Diff(ng)
+
+	var outfile *os.File = os.Stdout
+	if len(*outfileflag) > 0 {
+		outfile = create(*outfileflag)
+	}
+	if err := d.Write(outfile); err != nil {
+		log.Fatal(err)
+	}
+	if err := outfile.Close(); err != nil {
+		log.Fatal(err)
+	}
+
+	if len(*dotfileflag) > 0 {
+		dotfile := create(*dotfileflag)
+		wopts := zgr.WriteOptions{Order: zgr.InputOrder, EmitDefaults: true}
+		if err := d.Overlay().WriteWithOptions(dotfile, nil, wopts); err != nil {
+			log.Fatal(err)
+		}
+		if err := dotfile.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}
+	verb(1, "leaving main")
+
+	// Like diff(1), exit with status 1 if the graphs differ.
+	if !d.Empty() {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/thanm/grvutils/grparser"
+	"github.com/thanm/grvutils/zgr"
+)
+
+var verbflag = flag.Int("v", 0, "Verbose trace output level")
+var outfileflag = flag.String("o", "", "Output file")
+var policyflag = flag.String("p", "first", "Attribute conflict policy. One of {first,last,error,concat}.")
+var sepflag = flag.String("sep", ",", "Separator for values joined by the concat policy")
+
+func verb(vlevel int, s string, a ...interface{}) {
+	if *verbflag >= vlevel {
+		fmt.Printf(s, a...)
+		fmt.Printf("\n")
+	}
+}
+
+func usage(msg string) {
+	if len(msg) > 0 {
+		fmt.Fprintf(os.Stderr, "error: %s\n", msg)
+	}
+	fmt.Fprintf(os.Stderr, "usage: grmerge [flags] file1.graph file2.graph ...\n")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+var policies = map[string]zgr.ConflictPolicy{
+	"first":  zgr.FirstWins,
+	"last":   zgr.LastWins,
+	"error":  zgr.ErrorOnConflict,
+	"concat": zgr.Concatenate,
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("grmerge: ")
+	flag.Parse()
+	verb(1, "in main")
+	if flag.NArg() == 0 {
+		usage("no input files")
+	}
+	policy, ok := policies[*policyflag]
+	if !ok {
+		usage(fmt.Sprintf("illegal policy '%s'", *policyflag))
+	}
+	var graphs []*zgr.Graph
+	for _, path := range flag.Args() {
+		verb(1, "reading %s", path)
+		f, err := os.Open(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		g := zgr.NewGraph()
+		if err := grparser.ParseGraph(f, g); err != nil {
+			log.Fatalf("%s: %v", path, err)
+		}
+		f.Close()
+		graphs = append(graphs, g)
+	}
+	mg, err := zgr.Merge(zgr.MergeOptions{Policy: policy, Sep: *sepflag}, graphs...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var outfile *os.File = os.Stdout
+	if len(*outfileflag) > 0 {
+		verb(1, "opening %s", *outfileflag)
+		outfile, err = os.OpenFile(*outfileflag, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	wopts := zgr.WriteOptions{Order: zgr.InputOrder, EmitDefaults: true}
+	if err := mg.WriteWithOptions(outfile, nil, wopts); err != nil {
+		log.Fatal(err)
+	}
+	if err := outfile.Close(); err != nil {
+		log.Fatal(err)
+	}
+	verb(1, "leaving main")
+}
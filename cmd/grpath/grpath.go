@@ -0,0 +1,121 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/thanm/grvutils/gralg"
+	"github.com/thanm/grvutils/grparser"
+	"github.com/thanm/grvutils/zgr"
+)
+
+var verbflag = flag.Int("v", 0, "Verbose trace output level")
+var infileflag = flag.String("i", "", "Input file")
+var outfileflag = flag.String("o", "", "Output file")
+var fromflag = flag.String("from", "", "Source node ID")
+var toflag = flag.String("to", "", "Target node ID")
+var kflag = flag.Int("k", 1, "Number of shortest paths to find")
+var weightflag = flag.String("w", "", "Numeric edge attribute to use as weight (unweighted if empty)")
+var dotflag = flag.Bool("dot", false, "Write paths as a highlighted DOT subgraph instead of text")
+
+func verb(vlevel int, s string, a ...interface{}) {
+	if *verbflag >= vlevel {
+		fmt.Printf(s, a...)
+		fmt.Printf("\n")
+	}
+}
+
+func usage(msg string) {
+	if len(msg) > 0 {
+		fmt.Fprintf(os.Stderr, "error: %s\n", msg)
+	}
+	fmt.Fprintf(os.Stderr, "usage: grpath [flags]\n")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func lookup(g *zgr.Graph, id string) uint32 {
+	n := g.LookupNode(zgr.QuoteRaw(id))
+	if n == nil {
+		log.Fatalf("unable to locate node '%s'", id)
+	}
+	return n.Idx()
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("grpath: ")
+	flag.Parse()
+	verb(1, "in main")
+	if flag.NArg() != 0 {
+		usage("unknown extra args")
+	}
+	if *fromflag == "" || *toflag == "" {
+		usage("specify source and target node IDs with -from and -to")
+	}
+	if *kflag < 1 {
+		usage(fmt.Sprintf("illegal path count %d", *kflag))
+	}
+	var err error
+	var infile *os.File = os.Stdin
+	if len(*infileflag) > 0 {
+		verb(1, "opening %s", *infileflag)
+		infile, err = os.Open(*infileflag)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	var outfile *os.File = os.Stdout
+	if len(*outfileflag) > 0 {
+		verb(1, "opening %s", *outfileflag)
+		outfile, err = os.OpenFile(*outfileflag, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	g := zgr.NewGraph()
+	if err = grparser.ParseGraph(infile, g); err != nil {
+		log.Fatal(err)
+	}
+	src := lookup(g, *fromflag)
+	dst := lookup(g, *toflag)
+
+	var paths []gralg.Path
+	if *kflag == 1 && *weightflag == "" {
+		if p, ok := gralg.ShortestPath(g, src, dst); ok {
+			paths = append(paths, p)
+		}
+	} else {
+		paths, err = gralg.KShortestPaths(g, src, dst, *kflag, *weightflag)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if len(paths) == 0 {
+		log.Fatalf("no path from '%s' to '%s'", *fromflag, *toflag)
+	}
+
+	if *dotflag {
+		sg := gralg.HighlightPaths(g, paths)
+		wopts := zgr.WriteOptions{Order: zgr.InputOrder, EmitDefaults: true}
+		if err := sg.WriteWithOptions(outfile, nil, wopts); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		for i, p := range paths {
+			ids := make([]string, len(p.Nodes))
+			for j, v := range p.Nodes {
+				ids[j] = zgr.Unquote(g.GetNode(v).Id())
+			}
+			fmt.Fprintf(outfile, "%d: cost %g: %s\n", i+1, p.Cost,
+				strings.Join(ids, " -> "))
+		}
+	}
+	if err := outfile.Close(); err != nil {
+		log.Fatal(err)
+	}
+	verb(1, "leaving main")
+}
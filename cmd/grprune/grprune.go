@@ -3,21 +3,81 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"strings"
 
+	"github.com/thanm/grvutils/gralg"
+	"github.com/thanm/grvutils/grgraphml"
+	"github.com/thanm/grvutils/grjson"
+	"github.com/thanm/grvutils/grmermaid"
 	"github.com/thanm/grvutils/grparser"
 	"github.com/thanm/grvutils/grprune"
 	"github.com/thanm/grvutils/zgr"
 )
 
 var verbflag = flag.Int("v", 0, "Verbose trace output level")
-var depthflag = flag.Int("d", 4, "Prune depth from root.")
+var depthflag = flag.Int("d", 4, "Prune depth from root, for both walks (negative for unlimited).")
+var fwddepthflag = flag.Int("df", 0, "Forward prune depth from root, overriding -d (negative for unlimited).")
+var bwddepthflag = flag.Int("db", 0, "Backward prune depth from root, overriding -d (negative for unlimited).")
+var edgesflag = flag.String("edges", "", "Follow only edges matching this filter (e.g. 'kind=call,style!=dashed'; ',' separates terms), for both walks")
+var fwdedgesflag = flag.String("fedges", "", "Edge filter for the forward walk, overriding -edges")
+var bwdedgesflag = flag.String("bedges", "", "Edge filter for the backward walk, overriding -edges")
 var modeflag = flag.String("m", "both", "Prune mode. One of {fwd,bwd,both}.")
 var infileflag = flag.String("i", "", "Input file")
 var outfileflag = flag.String("o", "", "Output file")
-var rootidflag = flag.String("r", "", "Root node ID")
-var excludeflag = flag.String("e", "", "Nodes to exclude")
+var rootsflag listFlag
+var toflag listFlag
+var rootfileflag = flag.String("rf", "", "File of root node IDs, one per line ('#' starts a comment)")
+var colorflag = flag.Bool("color", false, "Color nodes by the root whose walk reached them")
+var frontierflag = flag.String("frontier", "none", "Show nodes cut off by the depth limit. One of {none,annotate,placeholders}.")
+var excludeflag = flag.String("e", "", "Nodes to exclude (IDs or selectors, comma-separated)")
+var bypassflag = flag.Bool("bypass", false, "Walk through nodes excluded with -e, joining their kept neighbors with dashed edges")
+var allflag = flag.Bool("all", false, "Use every node matched by an ambiguous root or exclude selector")
+var reduceflag = flag.Bool("reduce", false, "Apply transitive reduction to the pruned graph before writing")
+var orderflag = flag.String("order", "input", "Output order. One of {input,sorted}.")
+var informatflag = flag.String("if", "dot", "Input format. One of {dot,json,graphml,bin}.")
+var outformatflag = flag.String("of", "dot", "Output format. One of {dot,json,graphml,mermaid,bin}.")
+
+func init() {
+	flag.Var(&toflag, "to", "Target node IDs or selectors: keep only the nodes on paths from a root to a target, with path length bounded by -d/-df if given")
+	flag.Var(&rootsflag, "r", "Root node IDs or selectors (label:TEXT, labelre:RE, idre:RE, attr:KEY=VAL), comma-separated; may be repeated")
+}
+
+// listFlag collects the values of a repeatable, comma-separated flag.
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(v string) error {
+	for _, s := range strings.Split(v, ",") {
+		if s != "" {
+			*l = append(*l, s)
+		}
+	}
+	return nil
+}
+
+// readRootFile returns the node IDs listed in the file fn.
+func readRootFile(fn string) ([]string, error) {
+	data, err := os.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	var res []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			res = append(res, line)
+		}
+	}
+	return res, nil
+}
 
 func verb(vlevel int, s string, a ...interface{}) {
 	if *verbflag >= vlevel {
@@ -35,6 +95,24 @@ func usage(msg string) {
 	os.Exit(2)
 }
 
+// writeOptions returns the DOT writer options for an -order value.
+// Unlike zgr's default of index order with sorted attributes and no
+// default blocks, grprune writes in input order and keeps the input's
+// "node [...]" and "edge [...]" blocks, so that its output diffs
+// cleanly against its input.
+func writeOptions(order string) (zgr.WriteOptions, bool) {
+	wopts := zgr.WriteOptions{EmitDefaults: true}
+	switch order {
+	case "input":
+		wopts.Order = zgr.InputOrder
+	case "sorted":
+		wopts.Order = zgr.SortedOrder
+	default:
+		return wopts, false
+	}
+	return wopts, true
+}
+
 func main() {
 	log.SetFlags(0)
 	log.SetPrefix("grprune: ")
@@ -43,12 +121,30 @@ func main() {
 	if flag.NArg() != 0 {
 		usage("unknown extra args")
 	}
-	if *rootidflag == "" {
-		usage("specify root node ID with -r flag")
+	roots := []string(rootsflag)
+	if *rootfileflag != "" {
+		fr, err := readRootFile(*rootfileflag)
+		if err != nil {
+			log.Fatal(err)
+		}
+		roots = append(roots, fr...)
+	}
+	if len(roots) == 0 {
+		usage("specify root node IDs with -r or -rf flag")
 	}
 	if *modeflag != "both" && *modeflag != "fwd" && *modeflag != "bwd" {
 		usage(fmt.Sprintf("illegal mode '%s'", *modeflag))
 	}
+	wopts, ok := writeOptions(*orderflag)
+	if !ok {
+		usage(fmt.Sprintf("illegal order '%s'", *orderflag))
+	}
+	if f := *informatflag; f != "dot" && f != "json" && f != "graphml" && f != "bin" {
+		usage(fmt.Sprintf("illegal input format '%s'", f))
+	}
+	if f := *outformatflag; f != "dot" && f != "json" && f != "graphml" && f != "mermaid" && f != "bin" {
+		usage(fmt.Sprintf("illegal output format '%s'", f))
+	}
 	var err error
 	var infile *os.File = os.Stdin
 	if len(*infileflag) > 0 {
@@ -67,14 +163,98 @@ func main() {
 		}
 	}
 	g := zgr.NewGraph()
-	err = grparser.ParseGraph(infile, g)
+	switch *informatflag {
+	case "json":
+		err = grjson.Read(infile, g)
+	case "graphml":
+		err = grgraphml.Read(infile, g)
+	case "bin":
+		var data []byte
+		if data, err = io.ReadAll(infile); err == nil {
+			err = g.UnmarshalBinary(data)
+		}
+	default:
+		err = grparser.ParseGraph(infile, g)
+	}
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	err = grprune.PruneGraph(g, *rootidflag, *modeflag, *depthflag, *excludeflag, outfile)
+	// -df, -db, -fedges and -bedges only apply if given explicitly. Paths to a -to
+	// target are unbounded unless a depth is given.
+	fwd, bwd := *depthflag, *depthflag
+	fwdedges, bwdedges := *edgesflag, *edgesflag
+	if len(toflag) != 0 {
+		fwd = grprune.Unlimited
+	}
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "d":
+			fwd = *depthflag
+		case "df":
+			fwd = *fwddepthflag
+		case "db":
+			bwd = *bwddepthflag
+		case "fedges":
+			fwdedges = *fwdedgesflag
+		case "bedges":
+			bwdedges = *bwdedgesflag
+		}
+	})
+	var frontier grprune.FrontierMode
+	switch *frontierflag {
+	case "none":
+	case "annotate":
+		frontier = grprune.FrontierAnnotate
+	case "placeholders":
+		frontier = grprune.FrontierPlaceholders
+	default:
+		usage(fmt.Sprintf("unknown -frontier value '%s'", *frontierflag))
+	}
+	opts := grprune.Options{
+		Roots:      roots,
+		Mode:       *modeflag,
+		FwdDepth:   fwd,
+		BwdDepth:   bwd,
+		Exclude:    strings.Split(*excludeflag, ","),
+		To:         toflag,
+		FwdEdges:   fwdedges,
+		BwdEdges:   bwdedges,
+		Bypass:     *bypassflag,
+		AllMatches: *allflag,
+		Frontier:   frontier,
+		ColorRoots: *colorflag,
+	}
+	// Pruning only reads the graph, so walk the compact frozen form.
+	f := g.Freeze()
+	slice, err := grprune.Prune(f, opts)
+	if err != nil {
+		log.Fatal(err)
+	}
+	sg := slice.Graph(f)
+	if *reduceflag {
+		sg = gralg.TransitiveReduction(sg)
+	}
+	switch *outformatflag {
+	case "json":
+		err = grjson.Write(outfile, sg, nil)
+	case "graphml":
+		err = grgraphml.Write(outfile, sg, nil)
+	case "mermaid":
+		err = grmermaid.Write(outfile, sg, nil)
+	case "bin":
+		var data []byte
+		if data, err = sg.MarshalBinary(); err == nil {
+			_, err = outfile.Write(data)
+		}
+	default:
+		err = sg.WriteWithOptions(outfile, nil, wopts)
+	}
 	if err != nil {
 		log.Fatal(err)
 	}
+	if err := outfile.Close(); err != nil {
+		log.Fatal(err)
+	}
 	verb(1, "leaving main")
 }
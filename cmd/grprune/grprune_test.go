@@ -1,7 +1,13 @@
 package main
 
 import (
+	"strings"
 	"testing"
+
+	"github.com/thanm/grvutils/grparser"
+	"github.com/thanm/grvutils/grprune"
+	"github.com/thanm/grvutils/testutils"
+	"github.com/thanm/grvutils/zgr"
 )
 
 func dotest(inf string, t *testing.T) {
@@ -14,3 +20,55 @@ func TestBasic(t *testing.T) {
 		dotest(inf, t)
 	}
 }
+
+func TestOutputOrder(t *testing.T) {
+	ins := `digraph G {
+node [shape=box]
+"c" [label="C", color=red]
+"b" [label="B"]
+"a" [label="A"]
+"c" -> "b" [z=1 a=2]
+"c" -> "a"
+}
+`
+	g := zgr.NewGraph()
+	if err := grparser.ParseGraph(strings.NewReader(ins), g); err != nil {
+		t.Fatal(err)
+	}
+	f := g.Freeze()
+	slice, err := grprune.Prune(f, grprune.Options{Roots: []string{"c"}, Mode: "both", FwdDepth: 1, BwdDepth: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sg := slice.Graph(f)
+
+	// By default grprune keeps the input's order and default blocks.
+	cases := []struct{ order, want string }{
+		{*orderflag, ins},
+		{"sorted", `digraph G {
+node [shape=box]
+"a" [label="A"]
+"b" [label="B"]
+"c" [color=red, label="C"]
+"c" -> "a"
+"c" -> "b" [a=2 z=1]
+}
+`},
+	}
+	for _, c := range cases {
+		wopts, ok := writeOptions(c.order)
+		if !ok {
+			t.Fatalf("bad order %s", c.order)
+		}
+		var sb strings.Builder
+		if err := sg.WriteWithOptions(&sb, nil, wopts); err != nil {
+			t.Fatal(err)
+		}
+		if td := testutils.Check(sb.String(), c.want); td != "" {
+			t.Errorf("-order %s: %s", c.order, td)
+		}
+	}
+	if _, ok := writeOptions("bogus"); ok {
+		t.Errorf("writeOptions accepted a bad order")
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/thanm/grvutils/grparser"
+	"github.com/thanm/grvutils/grquery"
+	"github.com/thanm/grvutils/zgr"
+)
+
+var verbflag = flag.Int("v", 0, "Verbose trace output level")
+var infileflag = flag.String("i", "", "Input file")
+var outfileflag = flag.String("o", "", "Output file")
+var dotflag = flag.Bool("dot", false, "Write the matching nodes and the edges between them as DOT instead of listing IDs")
+var labelflag = flag.Bool("l", false, "Show node labels alongside IDs")
+
+func verb(vlevel int, s string, a ...interface{}) {
+	if *verbflag >= vlevel {
+		fmt.Printf(s, a...)
+		fmt.Printf("\n")
+	}
+}
+
+func usage(msg string) {
+	if len(msg) > 0 {
+		fmt.Fprintf(os.Stderr, "error: %s\n", msg)
+	}
+	fmt.Fprintf(os.Stderr, "usage: grquery [flags] <query>\n")
+	fmt.Fprintf(os.Stderr, "example: grquery -i g.dot 'shape==box && fontcolor~\"red\"'\n")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("grquery: ")
+	flag.Parse()
+	verb(1, "in main")
+	if flag.NArg() != 1 {
+		usage("specify a single query")
+	}
+	q, err := grquery.Parse(flag.Arg(0))
+	if err != nil {
+		usage(err.Error())
+	}
+	var infile *os.File = os.Stdin
+	if len(*infileflag) > 0 {
+		verb(1, "opening %s", *infileflag)
+		infile, err = os.Open(*infileflag)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	var outfile *os.File = os.Stdout
+	if len(*outfileflag) > 0 {
+		verb(1, "opening %s", *outfileflag)
+		outfile, err = os.OpenFile(*outfileflag, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	g := zgr.NewGraph()
+	if err = grparser.ParseGraph(infile, g); err != nil {
+		log.Fatal(err)
+	}
+
+	matches := q.Select(g)
+	verb(1, "%d of %d nodes match %s", len(matches), g.GetNodeCount(), q)
+	if *dotflag {
+		include := make(map[uint32]bool)
+		for _, idx := range matches {
+			include[idx] = true
+		}
+		opts := zgr.WriteOptions{Order: zgr.InputOrder, EmitDefaults: true}
+		err = g.WriteWithOptions(outfile, include, opts)
+	} else {
+		bw := bufio.NewWriter(outfile)
+		for _, idx := range matches {
+			n := g.GetNode(idx)
+			if *labelflag && n.Label() != "" {
+				fmt.Fprintf(bw, "%s %s\n", zgr.Unquote(n.Id()), n.Label())
+			} else {
+				fmt.Fprintf(bw, "%s\n", zgr.Unquote(n.Id()))
+			}
+		}
+		err = bw.Flush()
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := outfile.Close(); err != nil {
+		log.Fatal(err)
+	}
+	verb(1, "leaving main")
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/thanm/grvutils/gralg"
+	"github.com/thanm/grvutils/grparser"
+	"github.com/thanm/grvutils/zgr"
+)
+
+var verbflag = flag.Int("v", 0, "Verbose trace output level")
+var infileflag = flag.String("i", "", "Input file")
+var outfileflag = flag.String("o", "", "Output file")
+var topflag = flag.Int("n", 10, "Number of top fan-in/fan-out nodes to report")
+var jsonflag = flag.Bool("json", false, "Emit JSON instead of text")
+
+func verb(vlevel int, s string, a ...interface{}) {
+	if *verbflag >= vlevel {
+		fmt.Printf(s, a...)
+		fmt.Printf("\n")
+	}
+}
+
+func usage(msg string) {
+	if len(msg) > 0 {
+		fmt.Fprintf(os.Stderr, "error: %s\n", msg)
+	}
+	fmt.Fprintf(os.Stderr, "usage: grstat [flags]\n")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+type bucket struct {
+	Degree int `json:"degree"`
+	Count  int `json:"count"`
+}
+
+type degreeStats struct {
+	Min       int      `json:"min"`
+	Max       int      `json:"max"`
+	Mean      float64  `json:"mean"`
+	Histogram []bucket `json:"histogram"`
+}
+
+type nodeDegree struct {
+	Id     string `json:"id"`
+	Label  string `json:"label,omitempty"`
+	Degree int    `json:"degree"`
+}
+
+type keyCount struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+}
+
+type stats struct {
+	Name             string       `json:"name,omitempty"`
+	Directed         bool         `json:"directed"`
+	Nodes            int          `json:"nodes"`
+	Edges            int          `json:"edges"`
+	SelfLoops        int          `json:"self_loops"`
+	Sources          int          `json:"sources"`
+	Sinks            int          `json:"sinks"`
+	Isolated         int          `json:"isolated"`
+	WeakComponents   int          `json:"weak_components"`
+	StrongComponents int          `json:"strong_components"`
+	CyclicComponents int          `json:"cyclic_components"`
+	InDegree         degreeStats  `json:"in_degree"`
+	OutDegree        degreeStats  `json:"out_degree"`
+	TopFanIn         []nodeDegree `json:"top_fan_in"`
+	TopFanOut        []nodeDegree `json:"top_fan_out"`
+	NodeAttrKeys     []keyCount   `json:"node_attr_keys"`
+	EdgeAttrKeys     []keyCount   `json:"edge_attr_keys"`
+}
+
+func degreeSummary(degs []int) degreeStats {
+	var ds degreeStats
+	hist := make(map[int]int)
+	total := 0
+	for i, d := range degs {
+		if i == 0 || d < ds.Min {
+			ds.Min = d
+		}
+		ds.Max = max(ds.Max, d)
+		total += d
+		hist[d]++
+	}
+	if len(degs) != 0 {
+		ds.Mean = float64(total) / float64(len(degs))
+	}
+	for d, c := range hist {
+		ds.Histogram = append(ds.Histogram, bucket{Degree: d, Count: c})
+	}
+	sort.Slice(ds.Histogram, func(i, j int) bool {
+		return ds.Histogram[i].Degree < ds.Histogram[j].Degree
+	})
+	return ds
+}
+
+// topNodes returns the n nodes with the highest degree, breaking ties
+// by node index. Nodes with degree zero are not reported.
+func topNodes(g *zgr.Graph, degs []int, n int) []nodeDegree {
+	idx := make([]int, len(degs))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool { return degs[idx[i]] > degs[idx[j]] })
+	res := []nodeDegree{}
+	for _, v := range idx {
+		if len(res) >= n || degs[v] == 0 {
+			break
+		}
+		node := g.GetNode(uint32(v))
+		res = append(res, nodeDegree{
+			Id:     zgr.Unquote(node.Id()),
+			Label:  zgr.Unquote(node.Label()),
+			Degree: degs[v],
+		})
+	}
+	return res
+}
+
+func keyCounts(counts map[string]int) []keyCount {
+	res := []keyCount{}
+	for k, c := range counts {
+		res = append(res, keyCount{Key: k, Count: c})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Count != res[j].Count {
+			return res[i].Count > res[j].Count
+		}
+		return res[i].Key < res[j].Key
+	})
+	return res
+}
+
+func computeStats(g *zgr.Graph, top int) *stats {
+	s := &stats{
+		Name:     zgr.Unquote(g.Name()),
+		Directed: g.Directed(),
+		Nodes:    int(g.GetNodeCount()),
+	}
+	indeg := make([]int, s.Nodes)
+	outdeg := make([]int, s.Nodes)
+	nkeys := make(map[string]int)
+	ekeys := make(map[string]int)
+	for n := range g.Nodes() {
+		for k := range g.GetNodeAttrs(n) {
+			nkeys[k]++
+		}
+	}
+	for _, e := range g.Edges() {
+		s.Edges++
+		src, sink := g.GetEndpoints(e)
+		outdeg[src]++
+		indeg[sink]++
+		if src == sink {
+			s.SelfLoops++
+		}
+		for k := range g.GetEdgeAttrs(e) {
+			ekeys[k]++
+		}
+	}
+	for v := 0; v < s.Nodes; v++ {
+		switch {
+		case indeg[v] == 0 && outdeg[v] == 0:
+			s.Isolated++
+		case indeg[v] == 0:
+			s.Sources++
+		case outdeg[v] == 0:
+			s.Sinks++
+		}
+	}
+	s.WeakComponents = len(gralg.WCCs(g))
+	for _, c := range gralg.SCCs(g) {
+		s.StrongComponents++
+		if len(c) > 1 || g.HasEdge(c[0], c[0]) {
+			s.CyclicComponents++
+		}
+	}
+	s.InDegree = degreeSummary(indeg)
+	s.OutDegree = degreeSummary(outdeg)
+	s.TopFanIn = topNodes(g, indeg, top)
+	s.TopFanOut = topNodes(g, outdeg, top)
+	s.NodeAttrKeys = keyCounts(nkeys)
+	s.EdgeAttrKeys = keyCounts(ekeys)
+	return s
+}
+
+// writeText writes s to w in human-readable form, returning the first
+// error encountered writing to w.
+func writeText(w io.Writer, s *stats) error {
+	bw := bufio.NewWriter(w)
+	kind := "digraph"
+	if !s.Directed {
+		kind = "graph"
+	}
+	fmt.Fprintf(bw, "%s %s\n", kind, s.Name)
+	fmt.Fprintf(bw, "nodes: %d\n", s.Nodes)
+	fmt.Fprintf(bw, "edges: %d\n", s.Edges)
+	fmt.Fprintf(bw, "self loops: %d\n", s.SelfLoops)
+	fmt.Fprintf(bw, "sources: %d\n", s.Sources)
+	fmt.Fprintf(bw, "sinks: %d\n", s.Sinks)
+	fmt.Fprintf(bw, "isolated: %d\n", s.Isolated)
+	fmt.Fprintf(bw, "weakly connected components: %d\n", s.WeakComponents)
+	fmt.Fprintf(bw, "strongly connected components: %d (%d cyclic)\n",
+		s.StrongComponents, s.CyclicComponents)
+	for _, d := range []struct {
+		name string
+		ds   degreeStats
+	}{{"in-degree", s.InDegree}, {"out-degree", s.OutDegree}} {
+		fmt.Fprintf(bw, "%s: min %d max %d mean %.2f\n", d.name, d.ds.Min, d.ds.Max, d.ds.Mean)
+		for _, b := range d.ds.Histogram {
+			fmt.Fprintf(bw, "  %6d: %d\n", b.Degree, b.Count)
+		}
+	}
+	for _, t := range []struct {
+		name  string
+		nodes []nodeDegree
+	}{{"top fan-in", s.TopFanIn}, {"top fan-out", s.TopFanOut}} {
+		fmt.Fprintf(bw, "%s:\n", t.name)
+		for _, nd := range t.nodes {
+			if nd.Label != "" {
+				fmt.Fprintf(bw, "  %6d %s (%s)\n", nd.Degree, nd.Id, nd.Label)
+			} else {
+				fmt.Fprintf(bw, "  %6d %s\n", nd.Degree, nd.Id)
+			}
+		}
+	}
+	for _, a := range []struct {
+		name string
+		keys []keyCount
+	}{{"node attributes", s.NodeAttrKeys}, {"edge attributes", s.EdgeAttrKeys}} {
+		fmt.Fprintf(bw, "%s:\n", a.name)
+		for _, kc := range a.keys {
+			fmt.Fprintf(bw, "  %6d %s\n", kc.Count, kc.Key)
+		}
+	}
+
+	// bufio.Writer holds on to the first write error it sees.
+	return bw.Flush()
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("grstat: ")
+	flag.Parse()
+	verb(1, "in main")
+	if flag.NArg() != 0 {
+		usage("unknown extra args")
+	}
+	var err error
+	var infile *os.File = os.Stdin
+	if len(*infileflag) > 0 {
+		verb(1, "opening %s", *infileflag)
+		infile, err = os.Open(*infileflag)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	var outfile *os.File = os.Stdout
+	if len(*outfileflag) > 0 {
+		verb(1, "opening %s", *outfileflag)
+		outfile, err = os.OpenFile(*outfileflag, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	g := zgr.NewGraph()
+	if err = grparser.ParseGraph(infile, g); err != nil {
+		log.Fatal(err)
+	}
+	s := computeStats(g, *topflag)
+	if *jsonflag {
+		enc := json.NewEncoder(outfile)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(s)
+	} else {
+		err = writeText(outfile, s)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := outfile.Close(); err != nil {
+		log.Fatal(err)
+	}
+	verb(1, "leaving main")
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/thanm/grvutils/grparser"
+	"github.com/thanm/grvutils/zgr"
+)
+
+func TestStats(t *testing.T) {
+	f, err := os.Open("../grprune/testdata/g1.graphviz")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer f.Close()
+	g := zgr.NewGraph()
+	if err := grparser.ParseGraph(f, g); err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	s := computeStats(g, 1)
+	var sb strings.Builder
+	writeText(&sb, s)
+	want := `digraph Y
+nodes: 7
+edges: 8
+self loops: 0
+sources: 0
+sinks: 0
+isolated: 0
+weakly connected components: 1
+strongly connected components: 1 (1 cyclic)
+in-degree: min 1 max 2 mean 1.14
+       1: 6
+       2: 1
+out-degree: min 1 max 2 mean 1.14
+       1: 6
+       2: 1
+top fan-in:
+       2 c (C)
+top fan-out:
+       2 c (C)
+node attributes:
+       7 label
+edge attributes:
+       4 b
+       2 q
+       1 x
+       1 z
+`
+	if sb.String() != want {
+		t.Errorf("want:\n%s\ngot:\n%s\n", want, sb.String())
+	}
+}
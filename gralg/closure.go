@@ -0,0 +1,161 @@
+package gralg
+
+import (
+	"github.com/thanm/grvutils/zgr"
+)
+
+// bitset is a fixed-size set of small integers.
+type bitset []uint64
+
+func newBitset(n int) bitset {
+	return make(bitset, (n+63)/64)
+}
+
+func (b bitset) set(i uint32)      { b[i/64] |= 1 << (i % 64) }
+func (b bitset) has(i uint32) bool { return b[i/64]&(1<<(i%64)) != 0 }
+func (b bitset) union(o bitset) {
+	for i := range b {
+		b[i] |= o[i]
+	}
+}
+
+// componentReach computes, for each component of the condensation of
+// g, the set of components reachable from it by one or more edges.
+// The components are those returned by SCCs, so they are already in
+// topological order and can be processed back to front.
+func componentReach(g *zgr.Graph) ([][]uint32, []uint32, [][]uint32, []bitset) {
+	comps := SCCs(g)
+	compof := make([]uint32, g.GetNodeCount())
+	for ci, comp := range comps {
+		for _, v := range comp {
+			compof[v] = uint32(ci)
+		}
+	}
+	csuccs := make([][]uint32, len(comps))
+	seen := make(map[[2]uint32]bool)
+	for _, e := range g.Edges() {
+		src, sink := g.GetEndpoints(e)
+		cs, ct := compof[src], compof[sink]
+		if cs == ct || seen[[2]uint32{cs, ct}] {
+			continue
+		}
+		seen[[2]uint32{cs, ct}] = true
+		csuccs[cs] = append(csuccs[cs], ct)
+	}
+	reach := make([]bitset, len(comps))
+	for ci := len(comps) - 1; ci >= 0; ci-- {
+		reach[ci] = newBitset(len(comps))
+		for _, s := range csuccs[ci] {
+			reach[ci].set(s)
+			reach[ci].union(reach[s])
+		}
+	}
+	return comps, compof, csuccs, reach
+}
+
+// TransitiveReduction returns a copy of g with redundant edges removed:
+// an edge u -> v is dropped when v remains reachable from u without it.
+// For a DAG this is the unique transitive reduction. In a cyclic graph
+// each strongly connected component is reduced to a minimal set of its
+// own edges that keeps it strongly connected, and at most one edge is
+// kept between any pair of components. Only edges of g are ever kept,
+// so attributes are preserved and no synthetic edges are introduced.
+func TransitiveReduction(g *zgr.Graph) *zgr.Graph {
+	comps, compof, csuccs, reach := componentReach(g)
+
+	// Between components: c -> s is implied if s is reachable through
+	// some other successor of c.
+	implied := make([]bitset, len(comps))
+	for ci := range comps {
+		implied[ci] = newBitset(len(comps))
+		for _, s := range csuccs[ci] {
+			implied[ci].union(reach[s])
+		}
+	}
+	keep := make([]bool, 0)
+	used := make(map[[2]uint32]bool)
+	for _, e := range g.Edges() {
+		src, sink := g.GetEndpoints(e)
+		cs, ct := compof[src], compof[sink]
+		k := false
+		if cs != ct && !implied[cs].has(ct) && !used[[2]uint32{cs, ct}] {
+			used[[2]uint32{cs, ct}] = true
+			k = true
+		}
+		keep = append(keep, k)
+	}
+
+	// Within components: greedily drop each edge whose target is still
+	// reachable from its source inside the component without it.
+	adj := make([][]uint32, g.GetNodeCount()) // edge indices
+	for eid, e := range g.Edges() {
+		src, sink := g.GetEndpoints(e)
+		if src == sink {
+			// A self loop is only redundant if the node is already on
+			// some other cycle.
+			keep[eid] = len(comps[compof[src]]) == 1
+		} else if compof[src] == compof[sink] {
+			keep[eid] = true
+			adj[src] = append(adj[src], eid)
+		}
+	}
+	mark := make([]uint32, g.GetNodeCount())
+	epoch := uint32(0)
+	reaches := func(from, to uint32, skip uint32) bool {
+		epoch++
+		mark[from] = epoch
+		stack := []uint32{from}
+		for len(stack) != 0 {
+			v := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			for _, eid := range adj[v] {
+				if eid == skip || !keep[eid] {
+					continue
+				}
+				_, w := g.GetEndpoints(g.GetEdge(eid))
+				if w == to {
+					return true
+				}
+				if mark[w] != epoch {
+					mark[w] = epoch
+					stack = append(stack, w)
+				}
+			}
+		}
+		return false
+	}
+	for v := range adj {
+		for _, eid := range adj[v] {
+			_, w := g.GetEndpoints(g.GetEdge(eid))
+			if reaches(uint32(v), w, eid) {
+				keep[eid] = false
+			}
+		}
+	}
+
+	return g.Filter(nil, func(eid uint32, e *zgr.Edge) bool { return keep[eid] })
+}
+
+// TransitiveClosure returns a copy of g with an edge u -> v added
+// whenever v is reachable from u. Nodes on a cycle get a self loop.
+// Existing edges keep their attributes; added edges have none.
+func TransitiveClosure(g *zgr.Graph) *zgr.Graph {
+	comps, compof, _, reach := componentReach(g)
+	cg := g.Filter(nil, nil)
+	for n := range g.Nodes() {
+		v := n.Idx()
+		c := compof[v]
+		cyclic := len(comps[c]) > 1 || g.HasEdge(v, v)
+		for ci, comp := range comps {
+			if !reach[c].has(uint32(ci)) && !(uint32(ci) == c && cyclic) {
+				continue
+			}
+			for _, w := range comp {
+				if !cg.HasEdge(v, w) {
+					cg.AddEdgeOrdered(n.Id(), g.GetNode(w).Id(), nil)
+				}
+			}
+		}
+	}
+	return cg
+}
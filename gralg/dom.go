@@ -0,0 +1,221 @@
+package gralg
+
+import (
+	"slices"
+
+	"github.com/thanm/grvutils/zgr"
+)
+
+// DomTree is a dominator or post-dominator tree computed over a
+// zgr.Graph. Nodes that can't be reached from the root (or, for
+// post-dominators, can't reach it) are not part of the tree.
+type DomTree struct {
+	root  uint32
+	idom  []int64 // -1 for the root and for nodes not in the tree
+	preds [][]uint32
+}
+
+// Dominators computes the dominator tree of g rooted at entry, using
+// the iterative algorithm of Cooper, Harvey and Kennedy.
+func Dominators(g zgr.Topology, entry uint32) *DomTree {
+	return computeDoms(successors(g), entry)
+}
+
+// PostDominators computes the post-dominator tree of g rooted at exit,
+// that is, the dominator tree of the reversed graph. Graphs with more
+// than one exit should be given a single synthetic exit node first.
+func PostDominators(g zgr.Topology, exit uint32) *DomTree {
+	return computeDoms(predecessors(g), exit)
+}
+
+func computeDoms(adj [][]uint32, root uint32) *DomTree {
+	n := len(adj)
+	dt := &DomTree{root: root, idom: make([]int64, n)}
+	for i := range dt.idom {
+		dt.idom[i] = -1
+	}
+	if int(root) >= n {
+		return dt
+	}
+
+	// Postorder numbering via an iterative DFS from the root.
+	po := make([]int, n)
+	for i := range po {
+		po[i] = -1
+	}
+	var order []uint32
+	type frame struct {
+		v  uint32
+		ei int
+	}
+	visited := make([]bool, n)
+	visited[root] = true
+	stack := []frame{{v: root}}
+	for len(stack) != 0 {
+		f := &stack[len(stack)-1]
+		if f.ei < len(adj[f.v]) {
+			w := adj[f.v][f.ei]
+			f.ei++
+			if !visited[w] {
+				visited[w] = true
+				stack = append(stack, frame{v: w})
+			}
+			continue
+		}
+		po[f.v] = len(order)
+		order = append(order, f.v)
+		stack = stack[:len(stack)-1]
+	}
+
+	// Predecessors within the reachable part of the graph.
+	dt.preds = make([][]uint32, n)
+	for v := range adj {
+		if po[v] < 0 {
+			continue
+		}
+		for _, w := range adj[v] {
+			dt.preds[w] = append(dt.preds[w], uint32(v))
+		}
+	}
+
+	idom := make([]int64, n)
+	for i := range idom {
+		idom[i] = -1
+	}
+	idom[root] = int64(root)
+	intersect := func(a, b uint32) uint32 {
+		for a != b {
+			for po[a] < po[b] {
+				a = uint32(idom[a])
+			}
+			for po[b] < po[a] {
+				b = uint32(idom[b])
+			}
+		}
+		return a
+	}
+	for changed := true; changed; {
+		changed = false
+		// Visit in reverse postorder, skipping the root.
+		for i := len(order) - 2; i >= 0; i-- {
+			b := order[i]
+			newidom := int64(-1)
+			for _, p := range dt.preds[b] {
+				if idom[p] < 0 {
+					continue
+				}
+				if newidom < 0 {
+					newidom = int64(p)
+				} else {
+					newidom = int64(intersect(p, uint32(newidom)))
+				}
+			}
+			if newidom != idom[b] {
+				idom[b] = newidom
+				changed = true
+			}
+		}
+	}
+	for v := range idom {
+		if uint32(v) != root {
+			dt.idom[v] = idom[v]
+		}
+	}
+	return dt
+}
+
+// Root returns the entry (or exit, for post-dominators) node index.
+func (dt *DomTree) Root() uint32 {
+	return dt.root
+}
+
+// Idom returns the immediate dominator of v. The second result is false
+// for the root and for nodes not in the tree.
+func (dt *DomTree) Idom(v uint32) (uint32, bool) {
+	if int(v) >= len(dt.idom) || dt.idom[v] < 0 {
+		return 0, false
+	}
+	return uint32(dt.idom[v]), true
+}
+
+// Contains reports whether v is part of the tree.
+func (dt *DomTree) Contains(v uint32) bool {
+	return v == dt.root || (int(v) < len(dt.idom) && dt.idom[v] >= 0)
+}
+
+// Dominates reports whether a dominates b. Every node in the tree
+// dominates itself.
+func (dt *DomTree) Dominates(a, b uint32) bool {
+	if !dt.Contains(a) || !dt.Contains(b) {
+		return false
+	}
+	for {
+		if a == b {
+			return true
+		}
+		p, ok := dt.Idom(b)
+		if !ok {
+			return false
+		}
+		b = p
+	}
+}
+
+// Frontiers returns the dominance frontier of every node, indexed by
+// node: the nodes just beyond the region that the node dominates. For
+// a post-dominator tree these are the post-dominance frontiers
+// (control dependences). The root, which has no immediate dominator,
+// is in the frontier of every node on a cycle back to it, including
+// its own.
+func (dt *DomTree) Frontiers() [][]uint32 {
+	df := make([][]uint32, len(dt.idom))
+	idom := func(v uint32) uint32 {
+		if v == dt.root {
+			return v
+		}
+		return uint32(dt.idom[v])
+	}
+	for b := range dt.preds {
+		ub := uint32(b)
+		// The root is a join point as soon as it has a predecessor,
+		// the other path in being the entry to the graph.
+		isroot := ub == dt.root
+		if !dt.Contains(ub) || len(dt.preds[b]) < 2 && !(isroot && len(dt.preds[b]) != 0) {
+			continue
+		}
+		for _, p := range dt.preds[b] {
+			for r := p; isroot || r != idom(ub); r = idom(r) {
+				if !slices.Contains(df[r], ub) {
+					df[r] = append(df[r], ub)
+				}
+				if r == dt.root {
+					break
+				}
+			}
+		}
+	}
+	for _, f := range df {
+		slices.Sort(f)
+	}
+	return df
+}
+
+// Graph returns the tree as a new graph over the nodes of g, with an
+// edge from each node's immediate dominator to the node. Nodes keep
+// their IDs and attributes, so the result can be written with
+// zgr.Graph.Write.
+func (dt *DomTree) Graph(g *zgr.Graph) *zgr.Graph {
+	tg := zgr.NewGraph()
+	tg.SetName(g.Name())
+	for n := range g.Nodes() {
+		if dt.Contains(n.Idx()) {
+			tg.MakeNodeOrdered(n.Id(), g.GetNodeAttrsOrdered(n))
+		}
+	}
+	for n := range g.Nodes() {
+		if p, ok := dt.Idom(n.Idx()); ok {
+			tg.AddEdgeOrdered(g.GetNode(p).Id(), n.Id(), nil)
+		}
+	}
+	return tg
+}
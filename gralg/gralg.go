@@ -0,0 +1,399 @@
+package gralg
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/thanm/grvutils/zgr"
+)
+
+// Algorithms that only need the structure of a graph take a
+// zgr.Topology, so that they run on a zgr.Frozen graph as well as a
+// zgr.Graph.
+
+// successors returns the adjacency lists of g as node indices.
+func successors(g zgr.Topology) [][]uint32 {
+	adj := make([][]uint32, g.GetNodeCount())
+	for v := range adj {
+		for w := range g.SuccessorIndices(uint32(v)) {
+			adj[v] = append(adj[v], w)
+		}
+	}
+	return adj
+}
+
+// predecessors returns the reverse adjacency lists of g as node indices.
+func predecessors(g zgr.Topology) [][]uint32 {
+	radj := make([][]uint32, g.GetNodeCount())
+	for v := range radj {
+		for w := range g.PredecessorIndices(uint32(v)) {
+			radj[v] = append(radj[v], w)
+		}
+	}
+	return radj
+}
+
+type idxheap []uint32
+
+func (h idxheap) Len() int           { return len(h) }
+func (h idxheap) Less(i, j int) bool { return h[i] < h[j] }
+func (h idxheap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *idxheap) Push(x any)        { *h = append(*h, x.(uint32)) }
+func (h *idxheap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// TopoSort returns the node indices of g in topological order. When
+// several nodes are ready at once, the one with the lowest index comes
+// first, so the result is deterministic. An error is returned if g
+// contains a cycle.
+func TopoSort(g zgr.Topology) ([]uint32, error) {
+	adj := successors(g)
+	indeg := make([]int, len(adj))
+	for _, succs := range adj {
+		for _, s := range succs {
+			indeg[s]++
+		}
+	}
+	ready := &idxheap{}
+	for v := range adj {
+		if indeg[v] == 0 {
+			*ready = append(*ready, uint32(v))
+		}
+	}
+	heap.Init(ready)
+	order := make([]uint32, 0, len(adj))
+	for ready.Len() != 0 {
+		v := heap.Pop(ready).(uint32)
+		order = append(order, v)
+		for _, s := range adj[v] {
+			indeg[s]--
+			if indeg[s] == 0 {
+				heap.Push(ready, s)
+			}
+		}
+	}
+	if len(order) != len(adj) {
+		s := fmt.Sprintf("TopoSort: graph has a cycle (%d nodes not ordered)",
+			len(adj)-len(order))
+		return nil, errors.New(s)
+	}
+	return order, nil
+}
+
+// IsDAG reports whether g has no cycles.
+func IsDAG(g zgr.Topology) bool {
+	_, err := TopoSort(g)
+	return err == nil
+}
+
+// tarjan computes the strongly connected components of the subgraph of
+// adj induced by the nodes for which keep returns true (all nodes if
+// keep is nil). Components are returned in reverse topological order.
+// The walk is iterative so that deep graphs don't exhaust the stack.
+func tarjan(adj [][]uint32, keep func(uint32) bool) [][]uint32 {
+	n := len(adj)
+	index := make([]int, n) // 0 means unvisited
+	low := make([]int, n)
+	onstack := make([]bool, n)
+	stack := []uint32{}
+	var res [][]uint32
+	next := 1
+
+	type frame struct {
+		v  uint32
+		ei int
+	}
+	visit := func(v uint32) frame {
+		index[v] = next
+		low[v] = next
+		next++
+		stack = append(stack, v)
+		onstack[v] = true
+		return frame{v: v}
+	}
+
+	for s := 0; s < n; s++ {
+		if index[s] != 0 || (keep != nil && !keep(uint32(s))) {
+			continue
+		}
+		call := []frame{visit(uint32(s))}
+		for len(call) != 0 {
+			f := &call[len(call)-1]
+			if f.ei < len(adj[f.v]) {
+				w := adj[f.v][f.ei]
+				f.ei++
+				if keep != nil && !keep(w) {
+					continue
+				}
+				if index[w] == 0 {
+					call = append(call, visit(w))
+				} else if onstack[w] {
+					low[f.v] = min(low[f.v], index[w])
+				}
+				continue
+			}
+			v := f.v
+			call = call[:len(call)-1]
+			if len(call) != 0 {
+				p := call[len(call)-1].v
+				low[p] = min(low[p], low[v])
+			}
+			if low[v] == index[v] {
+				var comp []uint32
+				for {
+					w := stack[len(stack)-1]
+					stack = stack[:len(stack)-1]
+					onstack[w] = false
+					comp = append(comp, w)
+					if w == v {
+						break
+					}
+				}
+				sort.Slice(comp, func(i, j int) bool { return comp[i] < comp[j] })
+				res = append(res, comp)
+			}
+		}
+	}
+	return res
+}
+
+// SCCs returns the strongly connected components of g, computed with
+// Tarjan's algorithm. Components are listed in topological order (a
+// component appears before any component it has edges into), and the
+// node indices within each component are sorted.
+func SCCs(g zgr.Topology) [][]uint32 {
+	comps := tarjan(successors(g), nil)
+	for i, j := 0, len(comps)-1; i < j; i, j = i+1, j-1 {
+		comps[i], comps[j] = comps[j], comps[i]
+	}
+	return comps
+}
+
+// Condensation returns the condensation of g: a new graph with one node
+// per strongly connected component and an edge between two components
+// whenever g has an edge between their members. The second result maps
+// each node index of g to the index of its component node, which is
+// also its position in the list returned by SCCs.
+func Condensation(g *zgr.Graph) (*zgr.Graph, []uint32) {
+	comps := SCCs(g)
+	compof := make([]uint32, g.GetNodeCount())
+	cg := zgr.NewGraph()
+	cg.SetName(g.Name())
+	ids := make([]string, len(comps))
+	for ci, comp := range comps {
+		members := make([]string, len(comp))
+		for i, v := range comp {
+			compof[v] = uint32(ci)
+			members[i] = zgr.Unquote(g.GetNode(v).Id())
+		}
+		ids[ci] = fmt.Sprintf("\"scc%d\"", ci)
+		attrs := []zgr.Attr{
+			zgr.NewAttr("label", zgr.Quote(strings.Join(members, "\\n"))),
+			zgr.NewAttr("size", fmt.Sprintf("%d", len(comp))),
+		}
+		// IDs are unique by construction, so this can't fail.
+		cg.MakeNodeOrdered(ids[ci], attrs)
+	}
+	for _, e := range g.Edges() {
+		src, sink := g.GetEndpoints(e)
+		cs, ct := compof[src], compof[sink]
+		if cs == ct || cg.HasEdge(cs, ct) {
+			continue
+		}
+		cg.AddEdgeOrdered(ids[cs], ids[ct], nil)
+	}
+	return cg, compof
+}
+
+// Cycles enumerates the elementary cycles of g using Johnson's
+// algorithm, stopping once limit cycles have been found (limit <= 0
+// means no limit). Each cycle is a list of node indices beginning with
+// its lowest-indexed node; the closing edge back to the first node is
+// implied. A self loop is reported as a single-node cycle.
+func Cycles(g zgr.Topology, limit int) [][]uint32 {
+	adj := successors(g)
+	n := len(adj)
+	var res [][]uint32
+	full := func() bool {
+		return limit > 0 && len(res) >= limit
+	}
+
+	blocked := make([]bool, n)
+	bset := make([]map[uint32]bool, n)
+	incomp := make([]bool, n)
+	path := []uint32{}
+
+	// unblock and circuit are iterative, like tarjan, so that long
+	// cycles don't exhaust the stack.
+	unblock := func(u uint32) {
+		blocked[u] = false
+		work := []uint32{u}
+		for len(work) != 0 {
+			x := work[len(work)-1]
+			work = work[:len(work)-1]
+			for w := range bset[x] {
+				delete(bset[x], w)
+				if blocked[w] {
+					blocked[w] = false
+					work = append(work, w)
+				}
+			}
+		}
+	}
+
+	// circuit searches for cycles through s, as in Johnson's paper;
+	// each frame stands for a recursive call on v, with ei the next
+	// edge to try and found recording whether a cycle was found
+	// through v.
+	type frame struct {
+		v     uint32
+		ei    int
+		found bool
+	}
+	enter := func(v uint32) frame {
+		path = append(path, v)
+		blocked[v] = true
+		return frame{v: v}
+	}
+	circuit := func(s uint32) {
+		call := []frame{enter(s)}
+		for len(call) != 0 {
+			f := &call[len(call)-1]
+			if f.ei < len(adj[f.v]) && !full() {
+				w := adj[f.v][f.ei]
+				f.ei++
+				if !incomp[w] {
+					continue
+				}
+				if w == s {
+					res = append(res, append([]uint32(nil), path...))
+					f.found = true
+				} else if !blocked[w] {
+					call = append(call, enter(w))
+				}
+				continue
+			}
+			v, found := f.v, f.found
+			if found {
+				unblock(v)
+			} else {
+				for _, w := range adj[v] {
+					if incomp[w] {
+						if bset[w] == nil {
+							bset[w] = make(map[uint32]bool)
+						}
+						bset[w][v] = true
+					}
+				}
+			}
+			path = path[:len(path)-1]
+			call = call[:len(call)-1]
+			if found && len(call) != 0 {
+				call[len(call)-1].found = true
+			}
+		}
+	}
+
+	// Only nodes in nontrivial components of the whole graph can be
+	// on a cycle; skip the rest without recomputing components.
+	cyclic := make([]bool, n)
+	for _, c := range tarjan(adj, nil) {
+		if len(c) > 1 {
+			for _, v := range c {
+				cyclic[v] = true
+			}
+		}
+	}
+	for v := range adj {
+		for _, w := range adj[v] {
+			if int(w) == v {
+				cyclic[v] = true
+			}
+		}
+	}
+
+	for s := uint32(0); int(s) < n && !full(); s++ {
+		if !cyclic[s] {
+			continue
+		}
+		// Find the component containing s in the subgraph induced by
+		// nodes with index >= s.
+		start := s
+		comps := tarjan(adj, func(v uint32) bool { return v >= start })
+		var comp []uint32
+		for _, c := range comps {
+			if c[0] == s {
+				comp = c
+				break
+			}
+		}
+		selfloop := false
+		for _, w := range adj[s] {
+			if w == s {
+				selfloop = true
+			}
+		}
+		if len(comp) < 2 && !selfloop {
+			continue
+		}
+		for _, v := range comp {
+			incomp[v] = true
+			blocked[v] = false
+			bset[v] = nil
+		}
+		circuit(s)
+		for _, v := range comp {
+			incomp[v] = false
+		}
+	}
+	return res
+}
+
+// WCCs returns the weakly connected components of g, that is, the
+// connected components when edge direction is ignored. Components are
+// ordered by their lowest node index, and node indices within each
+// component are sorted.
+func WCCs(g zgr.Topology) [][]uint32 {
+	n := g.GetNodeCount()
+	parent := make([]uint32, n)
+	for i := range parent {
+		parent[i] = uint32(i)
+	}
+	find := func(v uint32) uint32 {
+		for parent[v] != v {
+			parent[v] = parent[parent[v]]
+			v = parent[v]
+		}
+		return v
+	}
+	for a := uint32(0); a < n; a++ {
+		for b := range g.SuccessorIndices(a) {
+			ra, rb := find(a), find(b)
+			if ra < rb {
+				parent[rb] = ra
+			} else if rb < ra {
+				parent[ra] = rb
+			}
+		}
+	}
+	compidx := make(map[uint32]int)
+	var res [][]uint32
+	for v := uint32(0); v < n; v++ {
+		r := find(v)
+		ci, ok := compidx[r]
+		if !ok {
+			ci = len(res)
+			compidx[r] = ci
+			res = append(res, nil)
+		}
+		res[ci] = append(res[ci], v)
+	}
+	return res
+}
@@ -0,0 +1,432 @@
+package gralg
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/thanm/grvutils/grparser"
+	"github.com/thanm/grvutils/testutils"
+	"github.com/thanm/grvutils/zgr"
+)
+
+func doparse(t *testing.T, ins string) *zgr.Graph {
+	g := zgr.NewGraph()
+	if err := grparser.ParseGraph(strings.NewReader(ins), g); err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	return g
+}
+
+func lists(ll [][]uint32) string {
+	var sb strings.Builder
+	for _, l := range ll {
+		sb.WriteString(fmt.Sprintf(" %v", l))
+	}
+	return sb.String()
+}
+
+// Nodes: a=0 b=1 c=2 d=3 e=4 f=5 g=6; cycles c->d->e->f->c and
+// c->b->a->g->c.
+const cyclic = `digraph Y {
+   "a" [label="A"]
+   "b" [label="B"]
+   "c" [label="C"]
+   "d" [label="D"]
+   "e" [label="E"]
+   "f" [label="F"]
+   "g" [label="G"]
+   "c" -> "b" [x=y]
+   "c" -> "d" [z=w]
+   "d" -> "e" [q=r]
+   "g" -> "c" [q=r]
+   "f" -> "c" [b=1]
+   "b" -> "a" [b=1]
+   "e" -> "f" [b=1]
+   "a" -> "g" [b=1]
+ }`
+
+const dag = `digraph D {
+   "e" "d" "c" "b" "a"
+   "e" -> "c"
+   "d" -> "c"
+   "c" -> "a"
+   "c" -> "b"
+   "d" -> "b"
+ }`
+
+const mixed = `digraph M {
+   "x" "y" "z" "w" "v"
+   "x" -> "y"
+   "y" -> "x"
+   "y" -> "z"
+   "z" -> "w"
+   "w" -> "z"
+   "w" -> "w"
+   "w" -> "v"
+ }`
+
+func TestTopoSort(t *testing.T) {
+	g := doparse(t, dag)
+	order, err := TopoSort(g)
+	if err != nil {
+		t.Fatalf("TopoSort: %v", err)
+	}
+	if got := fmt.Sprintf("%v", order); got != "[0 1 2 3 4]" {
+		t.Errorf("TopoSort got %s", got)
+	}
+	if !IsDAG(g) {
+		t.Errorf("IsDAG false for DAG")
+	}
+	if _, err := TopoSort(doparse(t, cyclic)); err == nil {
+		t.Errorf("TopoSort succeeded on cyclic graph")
+	}
+	if IsDAG(doparse(t, mixed)) {
+		t.Errorf("IsDAG true for cyclic graph")
+	}
+}
+
+func TestSCCs(t *testing.T) {
+	g := doparse(t, mixed)
+	got := lists(SCCs(g))
+	want := " [0 1] [2 3] [4]"
+	if got != want {
+		t.Errorf("SCCs got %s want %s", got, want)
+	}
+	got = lists(SCCs(doparse(t, dag)))
+	want = " [1] [0] [2] [3] [4]"
+	if got != want {
+		t.Errorf("SCCs got %s want %s", got, want)
+	}
+
+	// The structural algorithms run on a frozen graph too.
+	f := g.Freeze()
+	if got, want := lists(SCCs(f)), lists(SCCs(g)); got != want {
+		t.Errorf("SCCs on frozen graph got %s want %s", got, want)
+	}
+	if got, want := lists(WCCs(f)), lists(WCCs(g)); got != want {
+		t.Errorf("WCCs on frozen graph got %s want %s", got, want)
+	}
+	if got, want := lists(Cycles(f, 0)), lists(Cycles(g, 0)); got != want {
+		t.Errorf("Cycles on frozen graph got %s want %s", got, want)
+	}
+}
+
+func TestCondensation(t *testing.T) {
+	g := doparse(t, mixed)
+	cg, compof := Condensation(g)
+	exp := `N0: '"x\ny"' E: { 1 }
+		N1: '"z\nw"' E: { 2 }
+		N2: '"v"' E: { }`
+	td := testutils.Check(cg.String(), exp)
+	if td != "" {
+		t.Errorf(td)
+	}
+	if got := fmt.Sprintf("%v", compof); got != "[0 0 1 1 2]" {
+		t.Errorf("compof got %s", got)
+	}
+	if !IsDAG(cg) {
+		t.Errorf("condensation is not a DAG")
+	}
+}
+
+func TestCycles(t *testing.T) {
+	got := lists(Cycles(doparse(t, cyclic), 0))
+	want := " [0 6 2 1] [2 3 4 5]"
+	if got != want {
+		t.Errorf("Cycles got %s want %s", got, want)
+	}
+	got = lists(Cycles(doparse(t, mixed), 0))
+	want = " [0 1] [2 3] [3]"
+	if got != want {
+		t.Errorf("Cycles got %s want %s", got, want)
+	}
+	if n := len(Cycles(doparse(t, mixed), 2)); n != 2 {
+		t.Errorf("Cycles with limit 2 returned %d cycles", n)
+	}
+	if n := len(Cycles(doparse(t, dag), 0)); n != 0 {
+		t.Errorf("Cycles on DAG returned %d cycles", n)
+	}
+
+	// A single long ring, walked without recursion.
+	const n = 2000
+	ring := zgr.NewGraph()
+	for i := 0; i < n; i++ {
+		ring.MakeNodeOrdered(fmt.Sprintf("\"n%d\"", i), nil)
+	}
+	for i := 0; i < n; i++ {
+		ring.AddEdgeOrdered(fmt.Sprintf("\"n%d\"", i), fmt.Sprintf("\"n%d\"", (i+1)%n), nil)
+	}
+	if cs := Cycles(ring, 0); len(cs) != 1 || len(cs[0]) != n || cs[0][n-1] != n-1 {
+		t.Errorf("Cycles on ring of %d returned %d cycles", n, len(cs))
+	}
+}
+
+// Nodes: s=0 a=1 b=2 c=3 t=4 u=5
+const weighted = `digraph W {
+   "s" "a" "b" "c" "t" "u"
+   "s" -> "a" [weight=1]
+   "a" -> "t" [weight=5]
+   "s" -> "b" [weight=2]
+   "b" -> "c" [weight="1.5"]
+   "c" -> "t" [weight=1]
+   "a" -> "c" [weight=1]
+   "s" -> "t" [weight=10]
+ }`
+
+func pathstr(ps []Path) string {
+	var sb strings.Builder
+	for _, p := range ps {
+		sb.WriteString(fmt.Sprintf(" %v%v/%g", p.Nodes, p.Edges, p.Cost))
+	}
+	return sb.String()
+}
+
+func TestShortestPath(t *testing.T) {
+	g := doparse(t, weighted)
+	p, ok := ShortestPath(g, 0, 4)
+	if got := pathstr([]Path{p}); !ok || got != " [0 4][6]/1" {
+		t.Errorf("ShortestPath got %v %s", ok, got)
+	}
+	p, ok, err := WeightedShortestPath(g, 0, 4, "weight")
+	if err != nil {
+		t.Fatalf("WeightedShortestPath: %v", err)
+	}
+	if got := pathstr([]Path{p}); !ok || got != " [0 1 3 4][0 5 4]/3" {
+		t.Errorf("WeightedShortestPath got %v %s", ok, got)
+	}
+	if _, ok := ShortestPath(g, 4, 0); ok {
+		t.Errorf("ShortestPath found path from sink")
+	}
+	if _, ok, _ := WeightedShortestPath(g, 5, 0, "weight"); ok {
+		t.Errorf("WeightedShortestPath found path from isolated node")
+	}
+
+	bad := doparse(t, `digraph B { "x" "y" "x" -> "y" [weight=abc] }`)
+	if _, _, err := WeightedShortestPath(bad, 0, 1, "weight"); err == nil {
+		t.Errorf("expected error for non-numeric weight")
+	}
+	for _, w := range []string{"-1", "NaN"} {
+		bad = doparse(t, `digraph B { "x" "y" "x" -> "y" [weight="`+w+`"] }`)
+		_, err := EdgeWeights(bad, "weight")
+		if err == nil {
+			t.Errorf("expected error for weight %s", w)
+		} else if strings.Contains(err.Error(), "negative") != (w == "-1") {
+			t.Errorf("weight %s: unexpected error %v", w, err)
+		}
+	}
+
+	// Weights fall back to the edge defaults.
+	dg := doparse(t, `digraph D { edge [weight=3] "x" "y" "x" -> "y" "y" -> "x" [weight=2] }`)
+	ws, err := EdgeWeights(dg, "weight")
+	if err != nil {
+		t.Fatalf("EdgeWeights: %v", err)
+	}
+	if fmt.Sprint(ws) != "[3 2]" {
+		t.Errorf("EdgeWeights with defaults got %v, want [3 2]", ws)
+	}
+}
+
+func TestKShortestPaths(t *testing.T) {
+	g := doparse(t, weighted)
+	ps, err := KShortestPaths(g, 0, 4, 10, "weight")
+	if err != nil {
+		t.Fatalf("KShortestPaths: %v", err)
+	}
+	got := pathstr(ps)
+	want := " [0 1 3 4][0 5 4]/3 [0 2 3 4][2 3 4]/4.5 [0 1 4][0 1]/6 [0 4][6]/10"
+	if got != want {
+		t.Errorf("KShortestPaths got %s want %s", got, want)
+	}
+	ps, err = KShortestPaths(g, 0, 4, 2, "")
+	if err != nil {
+		t.Fatalf("KShortestPaths: %v", err)
+	}
+	got = pathstr(ps)
+	want = " [0 4][6]/1 [0 1 4][0 1]/2"
+	if got != want {
+		t.Errorf("KShortestPaths got %s want %s", got, want)
+	}
+}
+
+func TestHighlightPaths(t *testing.T) {
+	g := doparse(t, weighted)
+	ps, _ := KShortestPaths(g, 0, 4, 1, "weight")
+	sg := HighlightPaths(g, ps)
+	var sb strings.Builder
+	if err := sg.WriteWithOptions(&sb, nil, zgr.WriteOptions{Order: zgr.InputOrder}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	exp := `digraph W {
+		"s"  [color=red]
+		"a"  [color=red]
+		"c"  [color=red]
+		"t"  [color=red]
+		"s" -> "a" [weight=1 color=red penwidth=2]
+		"a" -> "t" [weight=5]
+		"c" -> "t" [weight=1 color=red penwidth=2]
+		"a" -> "c" [weight=1 color=red penwidth=2]
+		"s" -> "t" [weight=10]
+	}`
+	td := testutils.Check(sb.String(), exp)
+	if td != "" {
+		t.Errorf(td)
+	}
+}
+
+// Nodes: entry=0 a=1 b=2 c=3 d=4 exit=5 dead=6
+const cfg = `digraph CFG {
+   "entry" "a" "b" "c" "d" "exit" "dead"
+   "entry" -> "a"
+   "a" -> "b"
+   "a" -> "c"
+   "b" -> "d"
+   "c" -> "d"
+   "d" -> "a"
+   "d" -> "exit"
+   "dead" -> "d"
+ }`
+
+func idoms(dt *DomTree, n int) string {
+	var sb strings.Builder
+	for v := 0; v < n; v++ {
+		if p, ok := dt.Idom(uint32(v)); ok {
+			sb.WriteString(fmt.Sprintf(" %d", p))
+		} else {
+			sb.WriteString(" -")
+		}
+	}
+	return sb.String()
+}
+
+func TestDominators(t *testing.T) {
+	g := doparse(t, cfg)
+	dt := Dominators(g, 0)
+	if got, want := idoms(dt, 7), " - 0 1 1 1 4 -"; got != want {
+		t.Errorf("idoms got %s want %s", got, want)
+	}
+	if !dt.Dominates(1, 5) || dt.Dominates(2, 4) || !dt.Dominates(4, 4) {
+		t.Errorf("Dominates gave wrong answer")
+	}
+	if dt.Contains(6) {
+		t.Errorf("unreachable node in dominator tree")
+	}
+	if got, want := lists(dt.Frontiers()), " [] [1] [4] [4] [1] [] []"; got != want {
+		t.Errorf("frontiers got %s want %s", got, want)
+	}
+
+	pdt := PostDominators(g, 5)
+	if got, want := idoms(pdt, 7), " 1 4 4 4 5 - 4"; got != want {
+		t.Errorf("ipdoms got %s want %s", got, want)
+	}
+	if got, want := lists(pdt.Frontiers()), " [] [4] [1] [1] [4] [] []"; got != want {
+		t.Errorf("post frontiers got %s want %s", got, want)
+	}
+
+	// A back edge to the root puts the root in its own frontier.
+	loop := doparse(t, `digraph L { "r" "a" "b" "r" -> "a" "a" -> "b" "b" -> "r" }`)
+	if got, want := lists(Dominators(loop, 0).Frontiers()), " [0] [0] [0]"; got != want {
+		t.Errorf("loop frontiers got %s want %s", got, want)
+	}
+}
+
+func TestDomTreeGraph(t *testing.T) {
+	g := doparse(t, cfg)
+	tg := Dominators(g, 0).Graph(g)
+	var sb strings.Builder
+	if err := tg.WriteWithOptions(&sb, nil, zgr.WriteOptions{Order: zgr.SortedOrder}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	exp := `digraph CFG {
+		"a"
+		"b"
+		"c"
+		"d"
+		"entry"
+		"exit"
+		"a" -> "b"
+		"a" -> "c"
+		"a" -> "d"
+		"d" -> "exit"
+		"entry" -> "a"
+	}`
+	td := testutils.Check(sb.String(), exp)
+	if td != "" {
+		t.Errorf(td)
+	}
+}
+
+// Nodes: a=0 b=1 c=2 d=3 e=4; a->c and a->d are implied, as is one of
+// the parallel routes between the {d,e} cycle and the rest.
+const redundant = `digraph R {
+   "a" "b" "c" "d" "e"
+   "a" -> "b" [k=1]
+   "b" -> "c" [k=2]
+   "a" -> "c" [k=3]
+   "c" -> "d" [k=4]
+   "a" -> "d" [k=5]
+   "d" -> "e" [k=6]
+   "e" -> "d" [k=7]
+   "c" -> "e" [k=8]
+ }`
+
+func TestTransitiveReduction(t *testing.T) {
+	rg := TransitiveReduction(doparse(t, redundant))
+	var sb strings.Builder
+	if err := rg.WriteWithOptions(&sb, nil, zgr.WriteOptions{Order: zgr.InputOrder}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	exp := `digraph R {
+		"a"
+		"b"
+		"c"
+		"d"
+		"e"
+		"a" -> "b" [k=1]
+		"b" -> "c" [k=2]
+		"c" -> "d" [k=4]
+		"d" -> "e" [k=6]
+		"e" -> "d" [k=7]
+	}`
+	td := testutils.Check(sb.String(), exp)
+	if td != "" {
+		t.Errorf(td)
+	}
+
+	// Reducing a cycle with chords keeps just the cycle.
+	cyc := doparse(t, `digraph C { "x" "y" "z" "x" -> "y" "y" -> "z" "z" -> "x" "x" -> "z" "y" -> "y" }`)
+	rc := TransitiveReduction(cyc)
+	exp = `N0: '' E: { 1 }
+		N1: '' E: { 2 }
+		N2: '' E: { 0 }`
+	td = testutils.Check(rc.String(), exp)
+	if td != "" {
+		t.Errorf(td)
+	}
+}
+
+func TestTransitiveClosure(t *testing.T) {
+	cg := TransitiveClosure(doparse(t, redundant))
+	exp := `N0: '' E: { 1 2 3 4 }
+		N1: '' E: { 2 3 4 }
+		N2: '' E: { 3 4 }
+		N3: '' E: { 4 3 }
+		N4: '' E: { 3 4 }`
+	td := testutils.Check(cg.String(), exp)
+	if td != "" {
+		t.Errorf(td)
+	}
+	if !IsDAG(TransitiveClosure(doparse(t, dag))) {
+		t.Errorf("closure of DAG has a cycle")
+	}
+}
+
+func TestWCCs(t *testing.T) {
+	g := doparse(t, `digraph W { "a" "b" "c" "d" "e" "b" -> "a" "d" -> "c" "e" -> "c" }`)
+	if got, want := lists(WCCs(g)), " [0 1] [2 3 4]"; got != want {
+		t.Errorf("WCCs got %s want %s", got, want)
+	}
+	if got, want := lists(WCCs(doparse(t, cfg))), " [0 1 2 3 4 5 6]"; got != want {
+		t.Errorf("WCCs got %s want %s", got, want)
+	}
+}
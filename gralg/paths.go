@@ -0,0 +1,310 @@
+package gralg
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+
+	"github.com/thanm/grvutils/zgr"
+)
+
+// Path is a walk through a graph. Nodes holds the node indices visited
+// and Edges the indices of the edges taken between them, so
+// len(Edges) == len(Nodes)-1. Cost is the sum of the edge weights, or
+// the number of edges for unweighted searches.
+type Path struct {
+	Nodes []uint32
+	Edges []uint32
+	Cost  float64
+}
+
+// ShortestPath returns a path from src to dst with the fewest edges,
+// found by breadth-first search. The second result is false if dst is
+// not reachable from src.
+func ShortestPath(g *zgr.Graph, src, dst uint32) (Path, bool) {
+	nn := g.GetNodeCount()
+	if src >= nn || dst >= nn {
+		return Path{}, false
+	}
+	prev := make([]int64, nn)
+	for i := range prev {
+		prev[i] = -1
+	}
+	seen := make([]bool, nn)
+	seen[src] = true
+	queue := []uint32{src}
+	for len(queue) != 0 && !seen[dst] {
+		v := queue[0]
+		queue = queue[1:]
+		for eid, e := range g.OutEdges(g.GetNode(v)) {
+			_, sink := g.GetEndpoints(e)
+			if !seen[sink] {
+				seen[sink] = true
+				prev[sink] = int64(eid)
+				queue = append(queue, sink)
+			}
+		}
+	}
+	if !seen[dst] {
+		return Path{}, false
+	}
+	return buildPath(g, src, dst, prev, nil), true
+}
+
+// buildPath reconstructs the path ending at dst from the per-node
+// predecessor edges in prev.
+func buildPath(g *zgr.Graph, src, dst uint32, prev []int64, weights []float64) Path {
+	p := Path{Nodes: []uint32{dst}}
+	for v := dst; v != src; {
+		eid := uint32(prev[v])
+		v, _ = g.GetEndpoints(g.GetEdge(eid))
+		p.Nodes = append(p.Nodes, v)
+		p.Edges = append(p.Edges, eid)
+	}
+	slices.Reverse(p.Nodes)
+	slices.Reverse(p.Edges)
+	p.Cost = pathCost(p.Edges, weights)
+	return p
+}
+
+func pathCost(edges []uint32, weights []float64) float64 {
+	if weights == nil {
+		return float64(len(edges))
+	}
+	cost := 0.0
+	for _, eid := range edges {
+		cost += weights[eid]
+	}
+	return cost
+}
+
+// EdgeWeights returns the weight of every edge in g, taken from the
+// numeric attribute named weight. Edges without the attribute get
+// weight 1, which is also the Graphviz default for "weight", unless
+// an "edge [...]" default block gives one. An error is returned if a
+// value isn't a number or is negative.
+func EdgeWeights(g *zgr.Graph, weight string) ([]float64, error) {
+	var res []float64
+	for eid := range g.Edges() {
+		w := 1.0
+		if v, ok := g.EdgeAttr(eid, weight); ok {
+			var err error
+			w, err = strconv.ParseFloat(zgr.Unquote(v), 64)
+			if err != nil {
+				s := fmt.Sprintf("edge %d: bad %s value %s", eid, weight, v)
+				return nil, errors.New(s)
+			}
+			if math.IsNaN(w) {
+				s := fmt.Sprintf("edge %d: %s value %s is not a number", eid, weight, v)
+				return nil, errors.New(s)
+			}
+			if w < 0 {
+				s := fmt.Sprintf("edge %d: negative %s value %s", eid, weight, v)
+				return nil, errors.New(s)
+			}
+		}
+		res = append(res, w)
+	}
+	return res, nil
+}
+
+type distItem struct {
+	v    uint32
+	dist float64
+}
+
+type distHeap []distItem
+
+func (h distHeap) Len() int { return len(h) }
+func (h distHeap) Less(i, j int) bool {
+	if h[i].dist != h[j].dist {
+		return h[i].dist < h[j].dist
+	}
+	return h[i].v < h[j].v
+}
+func (h distHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *distHeap) Push(x any)   { *h = append(*h, x.(distItem)) }
+func (h *distHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// dijkstra finds a cheapest path from src to dst, ignoring the nodes
+// and edges marked in banNode and banEdge (either may be nil).
+func dijkstra(g *zgr.Graph, src, dst uint32, weights []float64, banNode []bool, banEdge map[uint32]bool) (Path, bool) {
+	nn := g.GetNodeCount()
+	dist := make([]float64, nn)
+	prev := make([]int64, nn)
+	done := make([]bool, nn)
+	for i := range dist {
+		dist[i] = math.Inf(1)
+		prev[i] = -1
+	}
+	dist[src] = 0
+	h := &distHeap{{v: src}}
+	for h.Len() != 0 {
+		it := heap.Pop(h).(distItem)
+		if done[it.v] {
+			continue
+		}
+		done[it.v] = true
+		if it.v == dst {
+			break
+		}
+		for eid, e := range g.OutEdges(g.GetNode(it.v)) {
+			_, sink := g.GetEndpoints(e)
+			if banEdge[eid] || (banNode != nil && banNode[sink]) || done[sink] {
+				continue
+			}
+			if nd := it.dist + weights[eid]; nd < dist[sink] {
+				dist[sink] = nd
+				prev[sink] = int64(eid)
+				heap.Push(h, distItem{v: sink, dist: nd})
+			}
+		}
+	}
+	if !done[dst] {
+		return Path{}, false
+	}
+	return buildPath(g, src, dst, prev, weights), true
+}
+
+// WeightedShortestPath returns a cheapest path from src to dst using
+// Dijkstra's algorithm, with edge weights taken from the attribute
+// named weight (see EdgeWeights). The second result is false if dst is
+// not reachable from src.
+func WeightedShortestPath(g *zgr.Graph, src, dst uint32, weight string) (Path, bool, error) {
+	nn := g.GetNodeCount()
+	if src >= nn || dst >= nn {
+		return Path{}, false, nil
+	}
+	weights, err := EdgeWeights(g, weight)
+	if err != nil {
+		return Path{}, false, err
+	}
+	p, ok := dijkstra(g, src, dst, weights, nil, nil)
+	return p, ok, nil
+}
+
+func lessPath(a, b Path) bool {
+	if a.Cost != b.Cost {
+		return a.Cost < b.Cost
+	}
+	if len(a.Edges) != len(b.Edges) {
+		return len(a.Edges) < len(b.Edges)
+	}
+	return slices.Compare(a.Edges, b.Edges) < 0
+}
+
+// KShortestPaths returns up to k loopless paths from src to dst in
+// order of increasing cost, using Yen's algorithm. If weight is empty
+// every edge costs 1, otherwise weights come from the named attribute
+// as in WeightedShortestPath.
+func KShortestPaths(g *zgr.Graph, src, dst uint32, k int, weight string) ([]Path, error) {
+	nn := g.GetNodeCount()
+	if src >= nn || dst >= nn || k <= 0 {
+		return nil, nil
+	}
+	var weights []float64
+	if weight == "" {
+		weights = make([]float64, g.GetEdgeCount())
+		for i := range weights {
+			weights[i] = 1
+		}
+	} else {
+		var err error
+		if weights, err = EdgeWeights(g, weight); err != nil {
+			return nil, err
+		}
+	}
+
+	first, ok := dijkstra(g, src, dst, weights, nil, nil)
+	if !ok {
+		return nil, nil
+	}
+	res := []Path{first}
+	var cands []Path
+	for len(res) < k {
+		last := res[len(res)-1]
+		for i := 0; i < len(last.Nodes)-1; i++ {
+			spur := last.Nodes[i]
+			rootNodes := last.Nodes[:i+1]
+			rootEdges := last.Edges[:i]
+
+			// Don't reuse the next edge of any accepted path that
+			// shares this root, and don't revisit root nodes.
+			banEdge := make(map[uint32]bool)
+			for _, p := range res {
+				if len(p.Nodes) > i && slices.Equal(p.Nodes[:i+1], rootNodes) {
+					banEdge[p.Edges[i]] = true
+				}
+			}
+			banNode := make([]bool, nn)
+			for _, v := range rootNodes[:i] {
+				banNode[v] = true
+			}
+
+			sp, ok := dijkstra(g, spur, dst, weights, banNode, banEdge)
+			if !ok {
+				continue
+			}
+			cand := Path{
+				Nodes: append(slices.Clone(rootNodes[:i]), sp.Nodes...),
+				Edges: append(slices.Clone(rootEdges), sp.Edges...),
+			}
+			cand.Cost = pathCost(cand.Edges, weights)
+			same := func(p Path) bool { return slices.Equal(p.Edges, cand.Edges) }
+			if !slices.ContainsFunc(res, same) && !slices.ContainsFunc(cands, same) {
+				cands = append(cands, cand)
+			}
+		}
+		if len(cands) == 0 {
+			break
+		}
+		best := 0
+		for i := range cands {
+			if lessPath(cands[i], cands[best]) {
+				best = i
+			}
+		}
+		res = append(res, cands[best])
+		cands = slices.Delete(cands, best, best+1)
+	}
+	return res, nil
+}
+
+// HighlightPaths returns the subgraph of g induced by the nodes on the
+// specified paths, with the path nodes and edges drawn in red so that
+// the routes stand out when rendered.
+func HighlightPaths(g *zgr.Graph, paths []Path) *zgr.Graph {
+	include := make(map[uint32]bool)
+	onpath := make(map[uint32]bool)
+	for _, p := range paths {
+		for _, v := range p.Nodes {
+			include[v] = true
+		}
+		for _, eid := range p.Edges {
+			onpath[eid] = true
+		}
+	}
+	sg := g.Subgraph(include)
+	for n := range sg.Nodes() {
+		sg.SetNodeAttr(n, "color", "red")
+	}
+	for eid := range onpath {
+		src, sink := g.GetEndpoints(g.GetEdge(eid))
+		ssrc := sg.LookupNode(g.GetNode(src).Id())
+		ssink := sg.LookupNode(g.GetNode(sink).Id())
+		if seid, ok := sg.FindEdge(ssrc.Idx(), ssink.Idx()); ok {
+			se := sg.GetEdge(seid)
+			sg.SetEdgeAttr(se, "color", "red")
+			sg.SetEdgeAttr(se, "penwidth", "2")
+		}
+	}
+	return sg
+}
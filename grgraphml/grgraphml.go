@@ -0,0 +1,372 @@
+// Package grgraphml reads and writes zgr graphs in GraphML, the XML
+// format used by tools such as yEd and Gephi.
+//
+// Graph, node and edge attributes are mapped to GraphML <key>
+// declarations, one per attribute name and domain, with a type inferred
+// from the values present (boolean, int, long, double or string). Node
+// and edge default attributes become the <default> of the matching key.
+// Values are converted with zgr.Unquote and zgr.Quote.
+// zgr has no notion of nested graphs, ports or hyperedges: nested graphs
+// are flattened into the top-level graph when reading, and ports and
+// hyperedges are ignored.
+package grgraphml
+
+import (
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/thanm/grvutils/zgr"
+)
+
+func escape(s string) string {
+	var sb strings.Builder
+	xml.EscapeText(&sb, []byte(s))
+	return sb.String()
+}
+
+// inferType returns the narrowest GraphML type that can hold all of
+// the (unquoted) values in vals.
+func inferType(vals []string) string {
+	isBool, isInt, isLong, isDouble := true, true, true, true
+	for _, v := range vals {
+		if v != "true" && v != "false" {
+			isBool = false
+		}
+		if _, err := strconv.ParseInt(v, 10, 32); err != nil {
+			isInt = false
+		}
+		if _, err := strconv.ParseInt(v, 10, 64); err != nil {
+			isLong = false
+		}
+		if _, err := strconv.ParseFloat(v, 64); err != nil {
+			isDouble = false
+		}
+	}
+	switch {
+	case len(vals) == 0:
+		return "string"
+	case isBool:
+		return "boolean"
+	case isInt:
+		return "int"
+	case isLong:
+		return "long"
+	case isDouble:
+		return "double"
+	}
+	return "string"
+}
+
+type keyDecl struct {
+	id, domain, name string
+	vals             []string
+	def              string
+	hasDef           bool
+}
+
+// keyTable collects the attribute keys used in each domain.
+type keyTable struct {
+	keys map[[2]string]*keyDecl
+}
+
+func (kt *keyTable) note(domain string, a zgr.Attr) {
+	k := [2]string{domain, a.Key()}
+	kd := kt.keys[k]
+	if kd == nil {
+		kd = &keyDecl{domain: domain, name: a.Key()}
+		kt.keys[k] = kd
+	}
+	kd.vals = append(kd.vals, zgr.Unquote(a.Val()))
+}
+
+func (kt *keyTable) setDefault(domain string, a zgr.Attr) {
+	kt.note(domain, a)
+	kd := kt.keys[[2]string{domain, a.Key()}]
+	kd.def = zgr.Unquote(a.Val())
+	kd.hasDef = true
+}
+
+func (kt *keyTable) id(domain, key string) string {
+	return kt.keys[[2]string{domain, key}].id
+}
+
+var domainOrder = map[string]int{"graph": 0, "node": 1, "edge": 2}
+
+// sorted assigns key IDs (d0, d1, ...) ordered by domain and then name,
+// and returns the declarations in that order.
+func (kt *keyTable) sorted() []*keyDecl {
+	var res []*keyDecl
+	for _, kd := range kt.keys {
+		res = append(res, kd)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].domain != res[j].domain {
+			return domainOrder[res[i].domain] < domainOrder[res[j].domain]
+		}
+		return res[i].name < res[j].name
+	})
+	for i, kd := range res {
+		kd.id = fmt.Sprintf("d%d", i)
+	}
+	return res
+}
+
+// Write writes the nodes of g selected by toinclude (all nodes if
+// toinclude is nil), along with the edges between them, as a GraphML
+// document. It returns the first error encountered writing to w.
+func Write(w io.Writer, g *zgr.Graph, toinclude map[uint32]bool) error {
+	emit := func(x uint32) bool {
+		return toinclude == nil || toinclude[x]
+	}
+	kt := &keyTable{keys: make(map[[2]string]*keyDecl)}
+	for _, a := range g.GetAttrsOrdered() {
+		kt.note("graph", a)
+	}
+	for _, a := range g.GetNodeDefaultsOrdered() {
+		kt.setDefault("node", a)
+	}
+	for _, a := range g.GetEdgeDefaultsOrdered() {
+		kt.setDefault("edge", a)
+	}
+	for n := range g.Nodes() {
+		if emit(n.Idx()) {
+			for _, a := range g.GetNodeAttrsOrdered(n) {
+				kt.note("node", a)
+			}
+		}
+	}
+	for _, e := range g.Edges() {
+		if src, sink := g.GetEndpoints(e); emit(src) && emit(sink) {
+			for _, a := range g.GetEdgeAttrsOrdered(e) {
+				kt.note("edge", a)
+			}
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString(xml.Header)
+	bw.WriteString("<graphml xmlns=\"http://graphml.graphdrawing.org/xmlns\"\n")
+	bw.WriteString("    xmlns:xsi=\"http://www.w3.org/2001/XMLSchema-instance\"\n")
+	bw.WriteString("    xsi:schemaLocation=\"http://graphml.graphdrawing.org/xmlns http://graphml.graphdrawing.org/xmlns/1.0/graphml.xsd\">\n")
+	for _, kd := range kt.sorted() {
+		fmt.Fprintf(bw, "  <key id=\"%s\" for=\"%s\" attr.name=\"%s\" attr.type=\"%s\"",
+			kd.id, kd.domain, escape(kd.name), inferType(kd.vals))
+		if !kd.hasDef {
+			bw.WriteString("/>\n")
+			continue
+		}
+		fmt.Fprintf(bw, ">\n    <default>%s</default>\n  </key>\n", escape(kd.def))
+	}
+
+	name := g.Name()
+	if name == "" {
+		name = "G"
+	}
+	edgedefault := "directed"
+	if !g.Directed() {
+		edgedefault = "undirected"
+	}
+	fmt.Fprintf(bw, "  <graph id=\"%s\" edgedefault=\"%s\">\n", escape(zgr.Unquote(name)), edgedefault)
+	data := func(indent, domain string, attrs []zgr.Attr) {
+		for _, a := range attrs {
+			fmt.Fprintf(bw, "%s<data key=\"%s\">%s</data>\n", indent,
+				kt.id(domain, a.Key()), escape(zgr.Unquote(a.Val())))
+		}
+	}
+	data("    ", "graph", g.GetAttrsOrdered())
+	for n := range g.Nodes() {
+		if !emit(n.Idx()) {
+			continue
+		}
+		attrs := g.GetNodeAttrsOrdered(n)
+		fmt.Fprintf(bw, "    <node id=\"%s\"", escape(zgr.Unquote(n.Id())))
+		if len(attrs) == 0 {
+			bw.WriteString("/>\n")
+			continue
+		}
+		bw.WriteString(">\n")
+		data("      ", "node", attrs)
+		bw.WriteString("    </node>\n")
+	}
+	nedge := 0
+	for _, e := range g.Edges() {
+		src, sink := g.GetEndpoints(e)
+		if !emit(src) || !emit(sink) {
+			continue
+		}
+		attrs := g.GetEdgeAttrsOrdered(e)
+		fmt.Fprintf(bw, "    <edge id=\"e%d\" source=\"%s\" target=\"%s\"", nedge,
+			escape(zgr.Unquote(g.GetNode(src).Id())), escape(zgr.Unquote(g.GetNode(sink).Id())))
+		nedge++
+		if len(attrs) == 0 {
+			bw.WriteString("/>\n")
+			continue
+		}
+		bw.WriteString(">\n")
+		data("      ", "edge", attrs)
+		bw.WriteString("    </edge>\n")
+	}
+	bw.WriteString("  </graph>\n</graphml>\n")
+	return bw.Flush()
+}
+
+type xmlData struct {
+	Key   string `xml:"key,attr"`
+	Inner string `xml:",innerxml"`
+	Text  string `xml:",chardata"`
+}
+
+type xmlKey struct {
+	Id      string `xml:"id,attr"`
+	For     string `xml:"for,attr"`
+	Name    string `xml:"attr.name,attr"`
+	Default *struct {
+		Text string `xml:",chardata"`
+	} `xml:"default"`
+}
+
+type xmlNode struct {
+	Id    string     `xml:"id,attr"`
+	Data  []xmlData  `xml:"data"`
+	Graph []xmlGraph `xml:"graph"`
+}
+
+type xmlEdge struct {
+	Source string    `xml:"source,attr"`
+	Target string    `xml:"target,attr"`
+	Data   []xmlData `xml:"data"`
+}
+
+type xmlGraph struct {
+	Id          string    `xml:"id,attr"`
+	EdgeDefault string    `xml:"edgedefault,attr"`
+	Data        []xmlData `xml:"data"`
+	Nodes       []xmlNode `xml:"node"`
+	Edges       []xmlEdge `xml:"edge"`
+}
+
+type xmlGraphML struct {
+	XMLName xml.Name   `xml:"graphml"`
+	Keys    []xmlKey   `xml:"key"`
+	Graphs  []xmlGraph `xml:"graph"`
+}
+
+func readError(s string, a ...any) error {
+	return errors.New("grgraphml: " + fmt.Sprintf(s, a...))
+}
+
+type reader struct {
+	g    *zgr.Graph
+	keys map[string]xmlKey
+}
+
+// attrs converts a list of <data> elements to attributes. Data with
+// element content (yEd's graphics, for example) or an undeclared key
+// is skipped.
+func (rd *reader) attrs(data []xmlData) []zgr.Attr {
+	var res []zgr.Attr
+	for _, d := range data {
+		k, ok := rd.keys[d.Key]
+		if !ok || strings.Contains(d.Inner, "<") {
+			continue
+		}
+		name := k.Name
+		if name == "" {
+			name = k.Id
+		}
+		res = append(res, zgr.NewAttr(name, zgr.Quote(d.Text)))
+	}
+	return res
+}
+
+func (rd *reader) addNodes(xg *xmlGraph) error {
+	for _, n := range xg.Nodes {
+		if err := rd.g.MakeNodeOrdered(zgr.Quote(n.Id), rd.attrs(n.Data)); err != nil {
+			return readError("%v", err)
+		}
+		for i := range n.Graph {
+			if err := rd.addNodes(&n.Graph[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (rd *reader) addEdges(xg *xmlGraph) error {
+	for _, n := range xg.Nodes {
+		for i := range n.Graph {
+			if err := rd.addEdges(&n.Graph[i]); err != nil {
+				return err
+			}
+		}
+	}
+	for _, e := range xg.Edges {
+		if err := rd.g.AddEdgeOrdered(zgr.Quote(e.Source), zgr.Quote(e.Target), rd.attrs(e.Data)); err != nil {
+			return readError("%v", err)
+		}
+	}
+	return nil
+}
+
+// Read parses a GraphML document from r into g. Only the first graph
+// in the document is read. Node IDs and attribute values are stored
+// quoted, so the result looks the same as a graph read by grparser from
+// DOT with quoted strings.
+func Read(r io.Reader, g *zgr.Graph) error {
+	var doc xmlGraphML
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return readError("%v", err)
+	}
+	if len(doc.Graphs) == 0 {
+		return readError("no <graph> element")
+	}
+	rd := &reader{g: g, keys: make(map[string]xmlKey)}
+	var ndefs, edefs []zgr.Attr
+	for _, k := range doc.Keys {
+		rd.keys[k.Id] = k
+		if k.Default == nil {
+			continue
+		}
+		name := k.Name
+		if name == "" {
+			name = k.Id
+		}
+		a := zgr.NewAttr(name, zgr.Quote(k.Default.Text))
+		switch k.For {
+		case "node":
+			ndefs = append(ndefs, a)
+		case "edge":
+			edefs = append(edefs, a)
+		}
+	}
+
+	xg := &doc.Graphs[0]
+	if xg.Id != "" {
+		g.SetName(zgr.Quote(xg.Id))
+	}
+	g.SetDirected(xg.EdgeDefault != "undirected")
+	if attrs := rd.attrs(xg.Data); len(attrs) != 0 {
+		if err := g.SetAttrsOrdered(attrs); err != nil {
+			return readError("%v", err)
+		}
+	}
+	if len(ndefs) != 0 {
+		g.SetNodeDefaults(ndefs)
+	}
+	if len(edefs) != 0 {
+		g.SetEdgeDefaults(edefs)
+	}
+	// Edges may refer to nodes declared later in the document, so all
+	// nodes are added first.
+	if err := rd.addNodes(xg); err != nil {
+		return err
+	}
+	return rd.addEdges(xg)
+}
@@ -0,0 +1,204 @@
+package grgraphml
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/thanm/grvutils/grparser"
+	"github.com/thanm/grvutils/testutils"
+	"github.com/thanm/grvutils/zgr"
+)
+
+func TestInferType(t *testing.T) {
+	cases := []struct {
+		vals []string
+		want string
+	}{
+		{nil, "string"},
+		{[]string{"true", "false"}, "boolean"},
+		{[]string{"1", "-2"}, "int"},
+		{[]string{"1", "10000000000"}, "long"},
+		{[]string{"1", "2.5"}, "double"},
+		{[]string{"1", "box"}, "string"},
+	}
+	for _, c := range cases {
+		if got := inferType(c.vals); got != c.want {
+			t.Errorf("inferType(%q) = %s, want %s", c.vals, got, c.want)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	ins := `digraph "G" {
+           rankdir="LR"
+           node [shape="box"]
+           "b" [label="x<y & \"z\""]
+           "a" [weight="2"]
+           "c" [weight="2.5"]
+           "b" -> "a" [bold="true"]
+           "a" -> "c"
+         }`
+	g := zgr.NewGraph()
+	if err := grparser.ParseGraph(strings.NewReader(ins), g); err != nil {
+		t.Fatal(err)
+	}
+
+	var sb strings.Builder
+	if err := Write(&sb, g, nil); err != nil {
+		t.Fatal(err)
+	}
+	expected := `<?xml version="1.0" encoding="UTF-8"?>
+<graphml xmlns="http://graphml.graphdrawing.org/xmlns"
+    xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance"
+    xsi:schemaLocation="http://graphml.graphdrawing.org/xmlns http://graphml.graphdrawing.org/xmlns/1.0/graphml.xsd">
+  <key id="d0" for="graph" attr.name="rankdir" attr.type="string"/>
+  <key id="d1" for="node" attr.name="label" attr.type="string"/>
+  <key id="d2" for="node" attr.name="shape" attr.type="string">
+    <default>box</default>
+  </key>
+  <key id="d3" for="node" attr.name="weight" attr.type="double"/>
+  <key id="d4" for="edge" attr.name="bold" attr.type="boolean"/>
+  <graph id="G" edgedefault="directed">
+    <data key="d0">LR</data>
+    <node id="b">
+      <data key="d1">x&lt;y &amp; &#34;z&#34;</data>
+    </node>
+    <node id="a">
+      <data key="d3">2</data>
+    </node>
+    <node id="c">
+      <data key="d3">2.5</data>
+    </node>
+    <edge id="e0" source="b" target="a">
+      <data key="d4">true</data>
+    </edge>
+    <edge id="e1" source="a" target="c"/>
+  </graph>
+</graphml>
+`
+	if td := testutils.Check(sb.String(), expected); td != "" {
+		t.Errorf(td)
+	}
+
+	rg := zgr.NewGraph()
+	if err := Read(strings.NewReader(sb.String()), rg); err != nil {
+		t.Fatal(err)
+	}
+	if d := g.Diff(rg); !d.Empty() {
+		var ds strings.Builder
+		d.Write(&ds)
+		t.Errorf("unexpected differences after round trip:\n%s", ds.String())
+	}
+	if rg.GetNodeDefaults()["shape"] != `"box"` {
+		t.Errorf("node defaults not preserved: %v", rg.GetNodeDefaults())
+	}
+}
+
+func TestReadNested(t *testing.T) {
+	// Edges before nodes, a nested graph and yEd-style graphics data.
+	ins := `<?xml version="1.0" encoding="UTF-8"?>
+<graphml xmlns="http://graphml.graphdrawing.org/xmlns" xmlns:y="http://www.yworks.com/xml/graphml">
+  <key id="k0" for="node" attr.name="color" attr.type="string"/>
+  <key id="k1" for="node" yfiles.type="nodegraphics"/>
+  <graph edgedefault="undirected">
+    <edge source="a" target="b"/>
+    <node id="a">
+      <data key="k0">red</data>
+      <data key="k1"><y:ShapeNode><y:Shape type="ellipse"/></y:ShapeNode></data>
+    </node>
+    <node id="grp">
+      <graph id="grp:">
+        <node id="b"/>
+        <edge source="b" target="grp"/>
+      </graph>
+    </node>
+  </graph>
+</graphml>
+`
+	g := zgr.NewGraph()
+	if err := Read(strings.NewReader(ins), g); err != nil {
+		t.Fatal(err)
+	}
+	var sb strings.Builder
+	if err := g.WriteWithOptions(&sb, nil, zgr.WriteOptions{Order: zgr.InputOrder}); err != nil {
+		t.Fatal(err)
+	}
+	expected := `graph G {
+"a"  [color="red"]
+"grp"
+"b"
+"b" -- "grp"
+"a" -- "b"
+}
+`
+	if td := testutils.Check(sb.String(), expected); td != "" {
+		t.Errorf(td)
+	}
+}
+
+func TestReadQuoted(t *testing.T) {
+	// Data values, node IDs and key defaults that look like DOT quoted
+	// strings are plain text and must keep their quotes.
+	ins := `<?xml version="1.0" encoding="UTF-8"?>
+<graphml xmlns="http://graphml.graphdrawing.org/xmlns">
+  <key id="k0" for="node" attr.name="label" attr.type="string">
+    <default>"dflt"</default>
+  </key>
+  <graph edgedefault="directed">
+    <node id="&quot;q&quot;">
+      <data key="k0">"x" and "y"</data>
+    </node>
+    <node id="trailing\"/>
+    <edge source="&quot;q&quot;" target="trailing\"/>
+  </graph>
+</graphml>
+`
+	g := zgr.NewGraph()
+	if err := Read(strings.NewReader(ins), g); err != nil {
+		t.Fatal(err)
+	}
+	var sb strings.Builder
+	opts := zgr.WriteOptions{Order: zgr.InputOrder, EmitDefaults: true}
+	if err := g.WriteWithOptions(&sb, nil, opts); err != nil {
+		t.Fatal(err)
+	}
+	expected := `digraph G {
+node [label="\"dflt\""]
+"\"q\""  [label="\"x\" and \"y\""]
+"trailing\\"
+"\"q\"" -> "trailing\\"
+}
+`
+	if td := testutils.Check(sb.String(), expected); td != "" {
+		t.Errorf(td)
+	}
+
+	rg := zgr.NewGraph()
+	if err := grparser.ParseGraph(strings.NewReader(sb.String()), rg); err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	if err := Write(&out, rg, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`<default>&#34;dflt&#34;</default>`,
+		`<node id="&#34;q&#34;">`, `&#34;x&#34; and &#34;y&#34;</data>`,
+		`<node id="trailing\"`} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output lacks %s:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	inputs := []string{
+		`<graphml></graphml>`,
+		`<graphml><graph><edge source="a" target="b"/></graph></graphml>`,
+		`not xml`,
+	}
+	for _, ins := range inputs {
+		if err := Read(strings.NewReader(ins), zgr.NewGraph()); err == nil {
+			t.Errorf("expected error reading %s", ins)
+		}
+	}
+}
@@ -0,0 +1,325 @@
+// Package grjson reads and writes zgr graphs in the JSON format
+// produced by Graphviz's "dot -Tjson" / "-Tjson0" output.
+//
+// JSON holds plain strings, so values are converted with zgr.Unquote
+// and zgr.Quote on the way out and in.
+// zgr has no notion of subgraphs, so subgraph objects are skipped when
+// reading and never produced when writing.
+package grjson
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/thanm/grvutils/zgr"
+)
+
+type jwriter struct {
+	bw    *bufio.Writer
+	first bool
+}
+
+func (jw *jwriter) str(s string) {
+	b, _ := json.Marshal(s)
+	jw.bw.Write(b)
+}
+
+// field writes a "key": value pair, where value is already encoded.
+func (jw *jwriter) field(indent, key, value string) {
+	if !jw.first {
+		jw.bw.WriteString(",")
+	}
+	jw.first = false
+	jw.bw.WriteString("\n")
+	jw.bw.WriteString(indent)
+	jw.str(key)
+	jw.bw.WriteString(": ")
+	jw.bw.WriteString(value)
+}
+
+func (jw *jwriter) strField(indent, key, value string) {
+	b, _ := json.Marshal(value)
+	jw.field(indent, key, string(b))
+}
+
+// attrFields writes the attributes in attrs, skipping any whose key
+// is already provided by override.
+func (jw *jwriter) attrFields(indent string, attrs []zgr.Attr, override []zgr.Attr) {
+	for _, a := range attrs {
+		shadowed := false
+		for _, o := range override {
+			if o.Key() == a.Key() {
+				shadowed = true
+			}
+		}
+		if !shadowed {
+			jw.strField(indent, a.Key(), zgr.Unquote(a.Val()))
+		}
+	}
+}
+
+// Write writes the nodes of g selected by toinclude (all nodes if
+// toinclude is nil), along with the edges between them, in Graphviz
+// JSON form. Node and edge default attributes are applied to each
+// object, as Graphviz does. It returns the first error encountered
+// writing to w.
+func Write(w io.Writer, g *zgr.Graph, toinclude map[uint32]bool) error {
+	jw := &jwriter{bw: bufio.NewWriter(w), first: true}
+	emit := func(x uint32) bool {
+		return toinclude == nil || toinclude[x]
+	}
+
+	jw.bw.WriteString("{")
+	name := g.Name()
+	if name == "" {
+		name = "G"
+	}
+	jw.strField("  ", "name", zgr.Unquote(name))
+	jw.field("  ", "directed", fmt.Sprintf("%v", g.Directed()))
+	jw.field("  ", "strict", fmt.Sprintf("%v", g.Strict()))
+	jw.attrFields("  ", g.GetAttrsOrdered(), nil)
+	jw.field("  ", "_subgraph_cnt", "0")
+
+	ndefs := g.GetNodeDefaultsOrdered()
+	edefs := g.GetEdgeDefaultsOrdered()
+
+	gvid := make(map[uint32]int)
+	jw.field("  ", "objects", "[")
+	nobj := 0
+	for n := range g.Nodes() {
+		if !emit(n.Idx()) {
+			continue
+		}
+		if nobj != 0 {
+			jw.bw.WriteString(",")
+		}
+		gvid[n.Idx()] = nobj
+		jw.bw.WriteString("\n    {")
+		jw.first = true
+		jw.field("      ", "_gvid", fmt.Sprintf("%d", nobj))
+		jw.strField("      ", "name", zgr.Unquote(n.Id()))
+		attrs := g.GetNodeAttrsOrdered(n)
+		jw.attrFields("      ", ndefs, attrs)
+		jw.attrFields("      ", attrs, nil)
+		jw.bw.WriteString("\n    }")
+		nobj++
+	}
+	jw.bw.WriteString("\n  ]")
+
+	jw.first = false
+	jw.field("  ", "edges", "[")
+	nedge := 0
+	for _, e := range g.Edges() {
+		src, sink := g.GetEndpoints(e)
+		if !emit(src) || !emit(sink) {
+			continue
+		}
+		if nedge != 0 {
+			jw.bw.WriteString(",")
+		}
+		jw.bw.WriteString("\n    {")
+		jw.first = true
+		jw.field("      ", "_gvid", fmt.Sprintf("%d", nedge))
+		jw.field("      ", "tail", fmt.Sprintf("%d", gvid[src]))
+		jw.field("      ", "head", fmt.Sprintf("%d", gvid[sink]))
+		attrs := g.GetEdgeAttrsOrdered(e)
+		jw.attrFields("      ", edefs, attrs)
+		jw.attrFields("      ", attrs, nil)
+		jw.bw.WriteString("\n    }")
+		nedge++
+	}
+	jw.bw.WriteString("\n  ]\n}\n")
+	return jw.bw.Flush()
+}
+
+type member struct {
+	key string
+	raw json.RawMessage
+}
+
+// readObject decodes a JSON object from dec, preserving the order of
+// its members.
+func readObject(dec *json.Decoder) ([]member, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if d, ok := tok.(json.Delim); !ok || d != '{' {
+		return nil, errors.New(fmt.Sprintf("expected JSON object, got %v", tok))
+	}
+	var res []member
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		var m member
+		m.key = tok.(string)
+		if err := dec.Decode(&m.raw); err != nil {
+			return nil, err
+		}
+		res = append(res, m)
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func objectFromRaw(raw json.RawMessage) ([]member, error) {
+	return readObject(json.NewDecoder(strings.NewReader(string(raw))))
+}
+
+// attrValue converts a JSON attribute value into the quoted form zgr
+// stores. Only scalar values are attributes; arrays and objects (such
+// as the xdot drawing operations in "-Tjson" output) are skipped.
+func attrValue(raw json.RawMessage) (string, bool) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return zgr.Quote(s), true
+	}
+	var v any
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return "", false
+	}
+	switch v.(type) {
+	case float64, bool:
+		return zgr.Quote(string(raw)), true
+	}
+	return "", false
+}
+
+func readError(s string, a ...any) error {
+	return errors.New("grjson: " + fmt.Sprintf(s, a...))
+}
+
+// Read parses a graph in Graphviz JSON form from r into g. Node IDs and
+// attribute values are stored quoted, so the result looks the same as
+// a graph read by grparser from DOT with quoted strings.
+func Read(r io.Reader, g *zgr.Graph) error {
+	top, err := readObject(json.NewDecoder(r))
+	if err != nil {
+		return readError("%v", err)
+	}
+	var gattrs []zgr.Attr
+	var objects, edges []json.RawMessage
+	nsub := 0
+	g.SetDirected(true)
+	for _, m := range top {
+		switch m.key {
+		case "name":
+			var name string
+			if err := json.Unmarshal(m.raw, &name); err != nil {
+				return readError("bad graph name: %v", err)
+			}
+			g.SetName(zgr.Quote(name))
+		case "directed", "strict":
+			var b bool
+			if err := json.Unmarshal(m.raw, &b); err != nil {
+				return readError("bad %s value: %v", m.key, err)
+			}
+			if m.key == "directed" {
+				g.SetDirected(b)
+			} else {
+				g.SetStrict(b)
+			}
+		case "_subgraph_cnt":
+			if err := json.Unmarshal(m.raw, &nsub); err != nil {
+				return readError("bad _subgraph_cnt: %v", err)
+			}
+		case "objects":
+			if err := json.Unmarshal(m.raw, &objects); err != nil {
+				return readError("bad objects list: %v", err)
+			}
+		case "edges":
+			if err := json.Unmarshal(m.raw, &edges); err != nil {
+				return readError("bad edges list: %v", err)
+			}
+		default:
+			if v, ok := attrValue(m.raw); ok {
+				gattrs = append(gattrs, zgr.NewAttr(m.key, v))
+			}
+		}
+	}
+	if len(gattrs) != 0 {
+		g.SetAttrsOrdered(gattrs)
+	}
+
+	// Subgraphs come first in the object list; everything after them
+	// is a node. Edges refer to nodes by _gvid.
+	ids := make(map[int]string)
+	for i, raw := range objects {
+		if i < nsub {
+			continue
+		}
+		obj, err := objectFromRaw(raw)
+		if err != nil {
+			return readError("object %d: %v", i, err)
+		}
+		gvid := i
+		var name string
+		var attrs []zgr.Attr
+		for _, m := range obj {
+			switch m.key {
+			case "_gvid":
+				if err := json.Unmarshal(m.raw, &gvid); err != nil {
+					return readError("object %d: bad _gvid: %v", i, err)
+				}
+			case "name":
+				if err := json.Unmarshal(m.raw, &name); err != nil {
+					return readError("object %d: bad name: %v", i, err)
+				}
+			default:
+				if v, ok := attrValue(m.raw); ok {
+					attrs = append(attrs, zgr.NewAttr(m.key, v))
+				}
+			}
+		}
+		id := zgr.Quote(name)
+		if err := g.MakeNodeOrdered(id, attrs); err != nil {
+			return readError("object %d: %v", i, err)
+		}
+		ids[gvid] = id
+	}
+
+	for i, raw := range edges {
+		obj, err := objectFromRaw(raw)
+		if err != nil {
+			return readError("edge %d: %v", i, err)
+		}
+		tail, head := -1, -1
+		var attrs []zgr.Attr
+		for _, m := range obj {
+			switch m.key {
+			case "_gvid":
+			case "tail", "head":
+				var v int
+				if err := json.Unmarshal(m.raw, &v); err != nil {
+					return readError("edge %d: bad %s: %v", i, m.key, err)
+				}
+				if m.key == "tail" {
+					tail = v
+				} else {
+					head = v
+				}
+			default:
+				if v, ok := attrValue(m.raw); ok {
+					attrs = append(attrs, zgr.NewAttr(m.key, v))
+				}
+			}
+		}
+		src, ok1 := ids[tail]
+		sink, ok2 := ids[head]
+		if !ok1 || !ok2 {
+			return readError("edge %d: unknown endpoint %d -> %d", i, tail, head)
+		}
+		if err := g.AddEdgeOrdered(src, sink, attrs); err != nil {
+			return readError("edge %d: %v", i, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+package grjson
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/thanm/grvutils/grparser"
+	"github.com/thanm/grvutils/testutils"
+	"github.com/thanm/grvutils/zgr"
+)
+
+func writeDot(t *testing.T, g *zgr.Graph) string {
+	var sb strings.Builder
+	opts := zgr.WriteOptions{Order: zgr.InputOrder, EmitDefaults: true}
+	if err := g.WriteWithOptions(&sb, nil, opts); err != nil {
+		t.Fatal(err)
+	}
+	return sb.String()
+}
+
+func TestRoundTrip(t *testing.T) {
+	ins := `strict digraph "my graph" {
+           rankdir="LR"
+           node [shape=box]
+           "b" [label="say \"hi\""]
+           "a" [color=red]
+           "c"
+           "b" -> "a" [weight="2"]
+           "a" -> "c"
+         }`
+	g := zgr.NewGraph()
+	if err := grparser.ParseGraph(strings.NewReader(ins), g); err != nil {
+		t.Fatal(err)
+	}
+
+	var sb strings.Builder
+	if err := Write(&sb, g, nil); err != nil {
+		t.Fatal(err)
+	}
+	expected := `{
+  "name": "my graph",
+  "directed": true,
+  "strict": true,
+  "rankdir": "LR",
+  "_subgraph_cnt": 0,
+  "objects": [
+    {
+      "_gvid": 0,
+      "name": "b",
+      "shape": "box",
+      "label": "say \"hi\""
+    },
+    {
+      "_gvid": 1,
+      "name": "a",
+      "shape": "box",
+      "color": "red"
+    },
+    {
+      "_gvid": 2,
+      "name": "c",
+      "shape": "box"
+    }
+  ],
+  "edges": [
+    {
+      "_gvid": 0,
+      "tail": 0,
+      "head": 1,
+      "weight": "2"
+    },
+    {
+      "_gvid": 1,
+      "tail": 1,
+      "head": 2
+    }
+  ]
+}
+`
+	if td := testutils.Check(sb.String(), expected); td != "" {
+		t.Errorf(td)
+	}
+
+	rg := zgr.NewGraph()
+	if err := Read(strings.NewReader(sb.String()), rg); err != nil {
+		t.Fatal(err)
+	}
+	if d := g.Diff(rg); !d.Empty() {
+		var ds strings.Builder
+		d.Write(&ds)
+		// Node defaults are folded into each node by Write, so those
+		// are the only differences expected.
+		for _, l := range strings.Split(strings.TrimSpace(ds.String()), "\n") {
+			if !strings.Contains(l, "+shape=\"box\"") {
+				t.Errorf("unexpected difference after round trip: %s", l)
+			}
+		}
+	}
+	if !rg.Strict() || !rg.Directed() || rg.Name() != `"my graph"` {
+		t.Errorf("graph kind/name not preserved: strict=%v directed=%v name=%s",
+			rg.Strict(), rg.Directed(), rg.Name())
+	}
+}
+
+func TestReadDotOutput(t *testing.T) {
+	// Trimmed down output of "dot -Tjson" for a graph with a cluster.
+	ins := `{
+  "name": "G",
+  "directed": false,
+  "strict": false,
+  "bb": "0,0,62,116",
+  "_draw_": [ { "op": "c", "grad": "none", "color": "#fffffe00" } ],
+  "_subgraph_cnt": 1,
+  "objects": [
+    {
+      "_gvid": 0,
+      "name": "cluster_x",
+      "nodes": [0],
+      "edges": [],
+      "subgraphs": []
+    },
+    {
+      "_gvid": 1,
+      "name": "a",
+      "pos": "27,90",
+      "width": 0.75
+    },
+    {
+      "_gvid": 2,
+      "name": "b",
+      "pos": "27,18"
+    }
+  ],
+  "edges": [
+    {
+      "_gvid": 0,
+      "tail": 1,
+      "head": 2,
+      "_draw_": [ { "op": "b", "points": [[27,72],[27,36]] } ]
+    }
+  ]
+}`
+	g := zgr.NewGraph()
+	if err := Read(strings.NewReader(ins), g); err != nil {
+		t.Fatal(err)
+	}
+	expected := `graph "G" {
+  bb="0,0,62,116"
+  "a"  [pos="27,90", width="0.75"]
+  "b"  [pos="27,18"]
+  "a" -- "b"
+}
+`
+	if td := testutils.Check(writeDot(t, g), expected); td != "" {
+		t.Errorf(td)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	inputs := []string{
+		`[1, 2]`,
+		`{"objects": [{"_gvid": 0, "name": "a"}], "edges": [{"tail": 0, "head": 3}]}`,
+		`{"directed": "yes"}`,
+	}
+	for _, ins := range inputs {
+		if err := Read(strings.NewReader(ins), zgr.NewGraph()); err == nil {
+			t.Errorf("expected error reading %s", ins)
+		}
+	}
+}
+
+func TestQuotedValues(t *testing.T) {
+	// Values holding quotes or backslashes must survive JSON -> DOT ->
+	// JSON unchanged.
+	vals := []string{`"x" and "y"`, `"hi"`, `trailing\`, `a\lb`}
+	var objs []string
+	for i, v := range vals {
+		b, _ := json.Marshal(v)
+		objs = append(objs, fmt.Sprintf(`{"_gvid": %d, "name": %s, "label": %s}`, i, b, b))
+	}
+	in := `{"name": "G", "directed": true, "objects": [` + strings.Join(objs, ", ") + `]}`
+	g := zgr.NewGraph()
+	if err := Read(strings.NewReader(in), g); err != nil {
+		t.Fatal(err)
+	}
+	dot := writeDot(t, g)
+	rg := zgr.NewGraph()
+	if err := grparser.ParseGraph(strings.NewReader(dot), rg); err != nil {
+		t.Fatalf("reparsing %s: %v", dot, err)
+	}
+	if d := g.Diff(rg); !d.Empty() {
+		t.Errorf("DOT round trip changed the graph:\n%s", dot)
+	}
+	var out strings.Builder
+	if err := Write(&out, rg, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range vals {
+		b, _ := json.Marshal(v)
+		if want := `"label": ` + string(b); !strings.Contains(out.String(), want) {
+			t.Errorf("output lacks %s:\n%s", want, out.String())
+		}
+	}
+}
@@ -20,6 +20,7 @@ const (
 	CONST
 	EQUAL
 	COMMA
+	SEMI
 	LBRACKET
 	RBRACKET
 	LCURLY
@@ -35,6 +36,7 @@ var ctab map[int]string = map[int]string{
 	CONST:      "const",
 	EQUAL:      "=",
 	COMMA:      ",",
+	SEMI:       ";",
 	LBRACKET:   "[",
 	RBRACKET:   "]",
 	LCURLY:     "{",
@@ -118,13 +120,18 @@ func (lxr *Lexer) readqual(sb *strings.Builder, f func(b, bp byte) bool) error {
 		if f(b, bp) == false {
 			return nil
 		}
-		bp = b
+		// A backslash escaped by another one doesn't escape what
+		// follows it, so "a\\" ends at the last quote.
+		if bp == '\\' && b == '\\' {
+			bp = 0
+		} else {
+			bp = b
+		}
 		if err = lxr.consume1(sb, b); err != nil {
 			return err
 		}
 		empty = false
 	}
-	return nil
 }
 
 func (lxr *Lexer) genTok(s string, t int) error {
@@ -224,6 +231,8 @@ func (lxr *Lexer) GetToken2() error {
 			return lxr.genTok("=", EQUAL)
 		case b == ',':
 			return lxr.genTok(",", COMMA)
+		case b == ';':
+			return lxr.genTok(";", SEMI)
 		case b == '{':
 			return lxr.genTok("{", LCURLY)
 		case b == '}':
@@ -241,7 +250,7 @@ func (lxr *Lexer) GetToken2() error {
 			if b == '>' {
 				return lxr.genTok("->", EDGEOPD)
 			} else if b == '-' {
-				return lxr.genTok("->", EDGEOPU)
+				return lxr.genTok("--", EDGEOPU)
 			} else {
 				s := fmt.Sprintf("error at line %d: unknown char: '%c'",
 					lxr.lno, b)
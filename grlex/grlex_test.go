@@ -58,6 +58,8 @@ func TestBasic(t *testing.T) {
          edge [q=r]
          "0x556c43bea3c0" [label="blah"]
          "0x556c42f19ba0" -> "0x556c43bea3c0" [label=" phony"]`,
+		"a; b",
+		`"a\\" b "c\\\"d"`,
 	}
 	var expected = []string{
 		"",
@@ -66,6 +68,8 @@ func TestBasic(t *testing.T) {
 		`(str '"foo"')`,
 		`(str '"foo \"bar\" baz"')`,
 		`(id 'digraph')(id 'n')({ '{')(id 'rankdir')(= '=')(str '"LR"')(id 'node')([ '[')(id 'fontsize')(= '=')(const '10')(, ',')(id 'shape')(= '=')(id 'box')(, ',')(id 'height')(= '=')(const '0.25')(] ']')(id 'edge')([ '[')(id 'q')(= '=')(id 'r')(] ']')(str '"0x556c43bea3c0"')([ '[')(id 'label')(= '=')(str '"blah"')(] ']')(str '"0x556c42f19ba0"')(-> '->')(str '"0x556c43bea3c0"')([ '[')(id 'label')(= '=')(str '" phony"')(] ']')`,
+		"(id 'a')(; ';')(id 'b')",
+		`(str '"a\\"')(id 'b')(str '"c\\\"d"')`,
 	}
 	for pos, ins := range inputs {
 		td := testTok(ins, expected[pos])
@@ -0,0 +1,204 @@
+// Package grmermaid writes zgr graphs as Mermaid flowcharts, for
+// pasting into Markdown documents that render Mermaid natively.
+//
+// Node labels and shapes, edge labels and styles, and the graph's
+// rankdir are mapped to their closest Mermaid equivalents. zgr does not
+// record DOT subgraphs, so clusters are taken from a node attribute
+// instead (see Options.ClusterAttr): nodes sharing a value are grouped
+// into a Mermaid subgraph of that name.
+package grmermaid
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/thanm/grvutils/zgr"
+)
+
+type Options struct {
+	// ClusterAttr names the node attribute used to group nodes into
+	// subgraphs; "cluster" if empty.
+	ClusterAttr string
+}
+
+// shapes maps Graphviz node shapes to Mermaid's opening and closing
+// delimiters. Shapes not listed use the plain rectangle.
+var shapes = map[string][2]string{
+	"ellipse":       {"([", "])"},
+	"oval":          {"([", "])"},
+	"circle":        {"((", "))"},
+	"doublecircle":  {"(((", ")))"},
+	"diamond":       {"{", "}"},
+	"hexagon":       {"{{", "}}"},
+	"cylinder":      {"[(", ")]"},
+	"parallelogram": {"[/", "/]"},
+	"trapezium":     {"[/", "\\]"},
+	"invtrapezium":  {"[\\", "/]"},
+	"cds":           {">", "]"},
+}
+
+// reserved words that can't be used as Mermaid node IDs.
+var reserved = map[string]bool{
+	"end": true, "graph": true, "subgraph": true, "flowchart": true,
+	"style": true, "class": true, "classdef": true, "click": true,
+	"linkstyle": true, "direction": true,
+}
+
+var directions = map[string]string{
+	"TB": "TB", "LR": "LR", "RL": "RL", "BT": "BT",
+}
+
+// text returns s as a quoted Mermaid string. Characters that would end
+// the string or be taken as markup are written as entity codes, and DOT
+// line breaks become <br>.
+func text(s string) string {
+	r := strings.NewReplacer(
+		"\\n", "<br>", "\\l", "<br>", "\\r", "<br>",
+		"\"", "#quot;", "#", "#35;", "<", "#lt;", ">", "#gt;",
+		"\n", "<br>")
+	s = r.Replace(s)
+	// The <br> inserted for line breaks must survive the escaping of
+	// angle brackets above.
+	s = strings.ReplaceAll(s, "#lt;br#gt;", "<br>")
+	return "\"" + s + "\""
+}
+
+// idMaker hands out unique Mermaid-safe identifiers.
+type idMaker struct {
+	used map[string]bool
+}
+
+func (im *idMaker) make(s string) string {
+	var sb strings.Builder
+	for i, c := range s {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '_':
+			sb.WriteRune(c)
+		case c >= '0' && c <= '9':
+			if i == 0 {
+				sb.WriteByte('_')
+			}
+			sb.WriteRune(c)
+		default:
+			sb.WriteByte('_')
+		}
+	}
+	base := sb.String()
+	if base == "" || reserved[strings.ToLower(base)] {
+		base = "_" + base
+	}
+	id := base
+	for n := 1; im.used[id]; n++ {
+		id = fmt.Sprintf("%s_%d", base, n)
+	}
+	im.used[id] = true
+	return id
+}
+
+// Write writes the nodes of g selected by toinclude (all nodes if
+// toinclude is nil), along with the edges between them, as a Mermaid
+// flowchart using the default options.
+func Write(w io.Writer, g *zgr.Graph, toinclude map[uint32]bool) error {
+	return WriteWithOptions(w, g, toinclude, Options{})
+}
+
+// WriteWithOptions is like Write but takes explicit options. It returns
+// the first error encountered writing to w.
+func WriteWithOptions(w io.Writer, g *zgr.Graph, toinclude map[uint32]bool, opts Options) error {
+	emit := func(x uint32) bool {
+		return toinclude == nil || toinclude[x]
+	}
+	cattr := opts.ClusterAttr
+	if cattr == "" {
+		cattr = "cluster"
+	}
+	ndefs := g.GetNodeDefaults()
+	edefs := g.GetEdgeDefaults()
+	attr := func(attrs, defs map[string]string, key string) string {
+		if v, ok := attrs[key]; ok {
+			return zgr.Unquote(v)
+		}
+		return zgr.Unquote(defs[key])
+	}
+
+	bw := bufio.NewWriter(w)
+	dir := directions[strings.ToUpper(zgr.Unquote(g.GetAttrs()["rankdir"]))]
+	if dir == "" {
+		dir = "TB"
+	}
+	fmt.Fprintf(bw, "flowchart %s\n", dir)
+
+	im := &idMaker{used: make(map[string]bool)}
+	ids := make(map[uint32]string)
+	var clusters []string
+	members := make(map[string][]string)
+	var loose []string
+	for n := range g.Nodes() {
+		if !emit(n.Idx()) {
+			continue
+		}
+		id := im.make(zgr.Unquote(n.Id()))
+		ids[n.Idx()] = id
+		attrs := g.GetNodeAttrs(n)
+		label := attr(attrs, ndefs, "label")
+		if label == "" || label == "\\N" {
+			label = zgr.Unquote(n.Id())
+		}
+		delims, ok := shapes[attr(attrs, ndefs, "shape")]
+		if !ok {
+			delims = [2]string{"[", "]"}
+		}
+		line := id + delims[0] + text(label) + delims[1]
+		if c := attr(attrs, ndefs, cattr); c != "" {
+			if _, ok := members[c]; !ok {
+				clusters = append(clusters, c)
+			}
+			members[c] = append(members[c], line)
+		} else {
+			loose = append(loose, line)
+		}
+	}
+	for _, line := range loose {
+		fmt.Fprintf(bw, "  %s\n", line)
+	}
+	for _, c := range clusters {
+		fmt.Fprintf(bw, "  subgraph %s[%s]\n", im.make("cluster_"+c), text(c))
+		for _, line := range members[c] {
+			fmt.Fprintf(bw, "    %s\n", line)
+		}
+		bw.WriteString("  end\n")
+	}
+
+	for _, e := range g.Edges() {
+		src, sink := g.GetEndpoints(e)
+		if !emit(src) || !emit(sink) {
+			continue
+		}
+		attrs := g.GetEdgeAttrs(e)
+		arrow := "-->"
+		if !g.Directed() {
+			arrow = "---"
+		}
+		switch style := attr(attrs, edefs, "style"); {
+		case style == "dashed" || style == "dotted":
+			arrow = "-.->"
+			if !g.Directed() {
+				arrow = "-.-"
+			}
+		case style == "bold":
+			arrow = "==>"
+			if !g.Directed() {
+				arrow = "==="
+			}
+		case style == "invis":
+			arrow = "~~~"
+		}
+		if label := attr(attrs, edefs, "label"); label != "" {
+			arrow += "|" + text(label) + "|"
+		}
+		fmt.Fprintf(bw, "  %s %s %s\n", ids[src], arrow, ids[sink])
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,70 @@
+package grmermaid
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/thanm/grvutils/grparser"
+	"github.com/thanm/grvutils/testutils"
+	"github.com/thanm/grvutils/zgr"
+)
+
+func dowrite(t *testing.T, ins string, opts Options) string {
+	g := zgr.NewGraph()
+	if err := grparser.ParseGraph(strings.NewReader(ins), g); err != nil {
+		t.Fatal(err)
+	}
+	var sb strings.Builder
+	if err := WriteWithOptions(&sb, g, nil, opts); err != nil {
+		t.Fatal(err)
+	}
+	return sb.String()
+}
+
+func TestWrite(t *testing.T) {
+	ins := `digraph G {
+           rankdir=LR
+           node [shape=box]
+           "main.go" [label="main\nentry"]
+           "end" [shape=diamond, label="a \"b\" <c>"]
+           "main_go" [shape=ellipse, group="core"]
+           "1x" [group="core"]
+           "main.go" -> "end" [label="call #1"]
+           "end" -> "main_go" [style=dashed]
+           "main_go" -> "1x" [style=bold]
+         }`
+	expected := `flowchart LR
+  main_go["main<br>entry"]
+  _end{"a #quot;b#quot; #lt;c#gt;"}
+  subgraph cluster_core["core"]
+    main_go_1(["main_go"])
+    _1x["1x"]
+  end
+  main_go -->|"call #35;1"| _end
+  _end -.-> main_go_1
+  main_go_1 ==> _1x
+`
+	actual := dowrite(t, ins, Options{ClusterAttr: "group"})
+	if td := testutils.Check(actual, expected); td != "" {
+		t.Errorf(td)
+	}
+}
+
+func TestWriteUndirected(t *testing.T) {
+	ins := `graph {
+           "a" [cluster="x"]
+           "b"
+           "a" -- "b"
+         }`
+	expected := `flowchart TB
+  b["b"]
+  subgraph cluster_x["x"]
+    a["a"]
+  end
+  a --- b
+`
+	actual := dowrite(t, ins, Options{})
+	if td := testutils.Check(actual, expected); td != "" {
+		t.Errorf(td)
+	}
+}
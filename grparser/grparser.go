@@ -11,14 +11,25 @@ import (
 )
 
 type pstate struct {
-	lxr    *grlex.Lexer
-	tok    grlex.Token
-	peeked bool
+	lxr      *grlex.Lexer
+	tok      grlex.Token
+	peeked   bool
+	directed bool
+	gattrs   []zgr.Attr
+	nattrs   []zgr.Attr
+	eattrs   []zgr.Attr
+
+	// In DOT, a "node [...]" or "edge [...]" block only applies to
+	// the statements after it. Blocks ahead of the first node or edge
+	// statement become the graph's defaults; later ones are collected
+	// here and applied explicitly to each node or edge that follows.
+	seenStmt   bool
+	latenattrs []zgr.Attr
+	lateeattrs []zgr.Attr
 }
 
 func mkerror(p *pstate, s string) error {
 	ers := fmt.Sprintf("error: line %d: %s", p.lxr.CurLine(), s)
-	panic(ers)
 	return errors.New(ers)
 }
 
@@ -83,9 +94,50 @@ var attrValClass map[int]bool = map[int]bool{
 	grlex.CONST:      true,
 }
 
-// Confusingly, some attribute lists seem to have commas and some do not.
+// addAttr appends key=val to attrs, preserving the order in which
+// keys first appear. A repeated key overwrites the earlier value.
+func addAttr(attrs []zgr.Attr, key, val string) []zgr.Attr {
+	for i := range attrs {
+		if attrs[i].Key() == key {
+			attrs[i] = zgr.NewAttr(key, val)
+			return attrs
+		}
+	}
+	return append(attrs, zgr.NewAttr(key, val))
+}
+
+// withDefaults returns attrs followed by those of defs whose keys
+// attrs doesn't set.
+func withDefaults(attrs, defs []zgr.Attr) []zgr.Attr {
+	for _, d := range defs {
+		found := false
+		for _, a := range attrs {
+			if a.Key() == d.Key() {
+				found = true
+				break
+			}
+		}
+		if !found {
+			attrs = append(attrs, d)
+		}
+	}
+	return attrs
+}
+
+var attrSepClass map[int]bool = map[int]bool{
+	grlex.COMMA: true,
+	grlex.SEMI:  true,
+}
+
+var graphNameClass map[int]bool = map[int]bool{
+	grlex.IDENTIFIER: true,
+	grlex.STRING:     true,
+}
+
+// Confusingly, some attribute lists seem to have commas and some do
+// not; DOT allows an optional ',' or ';' after each attribute.
 
-func parseAttrList(p *pstate, attrs map[string]string, comma bool) error {
+func parseAttrList(p *pstate, attrs *[]zgr.Attr) error {
 	var err error
 
 	// Attribute lists are optional
@@ -124,7 +176,7 @@ func parseAttrList(p *pstate, attrs map[string]string, comma bool) error {
 		}
 		val = p.tok.Str
 		if attrs != nil {
-			attrs[key] = val
+			*attrs = addAttr(*attrs, key, val)
 		}
 
 		// Take a peek at the next token
@@ -137,12 +189,10 @@ func parseAttrList(p *pstate, attrs map[string]string, comma bool) error {
 			break
 		}
 
-		if comma {
-			// Expect comma between attributes
-			if p.tok.Tok == grlex.COMMA {
-				if err = requiredToken(p, grlex.COMMA); err != nil {
-					return err
-				}
+		// Optional separator between attributes
+		if p.tok.Tok == grlex.COMMA || p.tok.Tok == grlex.SEMI {
+			if err = requiredTokenClass(p, attrSepClass); err != nil {
+				return err
 			}
 		}
 		if err = p.PeekToken(); err != nil {
@@ -159,12 +209,24 @@ func parseAttribute(p *pstate) error {
 	if err := requiredToken(p, grlex.IDENTIFIER); err != nil {
 		return err
 	}
+	key := p.tok.Str
 	if err := requiredToken(p, grlex.EQUAL); err != nil {
 		return err
 	}
 	if err := requiredTokenClass(p, attrValClass); err != nil {
 		return err
 	}
+	p.gattrs = addAttr(p.gattrs, key, p.tok.Str)
+	return nil
+}
+
+func parseGraphAttrs(p *pstate) error {
+	if err := requiredId(p, "graph"); err != nil {
+		return err
+	}
+	if err := parseAttrList(p, &p.gattrs); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -172,7 +234,10 @@ func parseNode(p *pstate) error {
 	if err := requiredId(p, "node"); err != nil {
 		return err
 	}
-	if err := parseAttrList(p, nil, true); err != nil {
+	if p.seenStmt {
+		return parseAttrList(p, &p.latenattrs)
+	}
+	if err := parseAttrList(p, &p.nattrs); err != nil {
 		return err
 	}
 	return nil
@@ -182,7 +247,10 @@ func parseEdge(p *pstate) error {
 	if err := requiredId(p, "edge"); err != nil {
 		return err
 	}
-	if err := parseAttrList(p, nil, false); err != nil {
+	if p.seenStmt {
+		return parseAttrList(p, &p.lateeattrs)
+	}
+	if err := parseAttrList(p, &p.eattrs); err != nil {
 		return err
 	}
 	return nil
@@ -192,12 +260,14 @@ func parseNodeDef(p *pstate, g *zgr.Graph, id string, pass int) error {
 
 	// ID has already been parsed at this point
 
-	attrs := make(map[string]string)
-	if err := parseAttrList(p, attrs, true); err != nil {
+	var attrs []zgr.Attr
+	if err := parseAttrList(p, &attrs); err != nil {
 		return err
 	}
+	p.seenStmt = true
 	if pass == 1 {
-		if err := g.MakeNode(id, attrs); err != nil {
+		attrs = withDefaults(attrs, p.latenattrs)
+		if err := g.MakeNodeOrdered(id, attrs); err != nil {
 			return err
 		}
 	}
@@ -206,22 +276,28 @@ func parseNodeDef(p *pstate, g *zgr.Graph, id string, pass int) error {
 
 func parseEdgeDef(p *pstate, g *zgr.Graph, src string, pass int) error {
 
-	// ID has already been parsed; now parse "-> dest"
-	if err := requiredToken(p, grlex.EDGEOPD); err != nil {
+	// ID has already been parsed; now parse "-> dest" or "-- dest"
+	edgeop := grlex.EDGEOPU
+	if p.directed {
+		edgeop = grlex.EDGEOPD
+	}
+	if err := requiredToken(p, edgeop); err != nil {
 		return err
 	}
 	if err := requiredToken(p, grlex.STRING); err != nil {
 		return err
 	}
 	sink := p.tok.Str
-	attrs := make(map[string]string)
-	if err := parseAttrList(p, attrs, false); err != nil {
+	var attrs []zgr.Attr
+	if err := parseAttrList(p, &attrs); err != nil {
 		return err
 	}
 	//	fmt.Fprintf(os.Stderr, "parseEdgeDef: %d attrs\n", len(attrs))
 
+	p.seenStmt = true
 	if pass == 2 {
-		if err := g.AddEdge(src, sink, attrs); err != nil {
+		attrs = withDefaults(attrs, p.lateeattrs)
+		if err := g.AddEdgeOrdered(src, sink, attrs); err != nil {
 			return err
 		}
 	}
@@ -256,16 +332,45 @@ func parse(g *zgr.Graph, lxr *grlex.Lexer, pass int) error {
 	state := pstate{lxr: lxr}
 	p := &state
 
-	// Preamble: graph <name> {
-	if err := requiredId(p, "digraph"); err != nil {
+	// Preamble: [strict] {digraph|graph} [<name>] {
+	if err := requiredToken(p, grlex.IDENTIFIER); err != nil {
 		return err
 	}
-	if err := requiredToken(p, grlex.IDENTIFIER); err != nil {
+	strict := false
+	if p.tok.Str == "strict" {
+		strict = true
+		if err := requiredToken(p, grlex.IDENTIFIER); err != nil {
+			return err
+		}
+	}
+	switch p.tok.Str {
+	case "digraph":
+		p.directed = true
+	case "graph":
+		p.directed = false
+	default:
+		s := fmt.Sprintf("expected 'digraph' or 'graph', got identifier '%s'",
+			p.tok.Str)
+		return mkerror(p, s)
+	}
+	if err = p.PeekToken(); err != nil {
 		return err
 	}
+	name := ""
+	if p.tok.Tok == grlex.IDENTIFIER || p.tok.Tok == grlex.STRING {
+		if err := requiredTokenClass(p, graphNameClass); err != nil {
+			return err
+		}
+		name = p.tok.Str
+	}
 	if err := requiredToken(p, grlex.LCURLY); err != nil {
 		return err
 	}
+	if pass == 1 {
+		g.SetName(name)
+		g.SetStrict(strict)
+		g.SetDirected(p.directed)
+	}
 
 	// Parse a series of node/edge clauses
 	done := false
@@ -280,6 +385,12 @@ func parse(g *zgr.Graph, lxr *grlex.Lexer, pass int) error {
 		case grlex.RCURLY:
 			done = true
 			break
+		case grlex.SEMI:
+			// Statement terminators are optional; just skip them.
+			if err = requiredToken(p, grlex.SEMI); err != nil {
+				return err
+			}
+			continue
 		case grlex.IDENTIFIER:
 			if p.tok.Str == "node" {
 				if err = parseNode(p); err != nil {
@@ -293,6 +404,12 @@ func parse(g *zgr.Graph, lxr *grlex.Lexer, pass int) error {
 				}
 				continue
 			}
+			if p.tok.Str == "graph" {
+				if err = parseGraphAttrs(p); err != nil {
+					return err
+				}
+				continue
+			}
 
 			// Graph attribute
 			if err = parseAttribute(p); err != nil {
@@ -312,7 +429,7 @@ func parse(g *zgr.Graph, lxr *grlex.Lexer, pass int) error {
 			}
 
 			// edge def: "foo" -> ...
-			if p.tok.Tok == grlex.EDGEOPD {
+			if p.tok.Tok == grlex.EDGEOPD || p.tok.Tok == grlex.EDGEOPU {
 				if err := parseEdgeDef(p, g, src, pass); err != nil {
 					return err
 				}
@@ -337,6 +454,18 @@ func parse(g *zgr.Graph, lxr *grlex.Lexer, pass int) error {
 		return err
 	}
 
+	if pass == 1 && len(p.gattrs) != 0 {
+		if err := g.SetAttrsOrdered(p.gattrs); err != nil {
+			return err
+		}
+	}
+	if pass == 1 && len(p.nattrs) != 0 {
+		g.SetNodeDefaults(p.nattrs)
+	}
+	if pass == 1 && len(p.eattrs) != 0 {
+		g.SetEdgeDefaults(p.eattrs)
+	}
+
 	return nil
 }
 
@@ -67,3 +67,189 @@ func TestBasic(t *testing.T) {
 		}
 	}
 }
+
+func TestPreserve(t *testing.T) {
+	ins := `strict digraph "my graph" {
+           rankdir=LR
+           graph [splines=ortho]
+           "b" [shape=box, label="B"]
+           "a" [label="A", color=red]
+           "b" -> "a" [z=1 a=2]
+         }`
+	g := zgr.NewGraph()
+	if err := ParseGraph(strings.NewReader(ins), g); err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if g.Name() != `"my graph"` || !g.Strict() || !g.Directed() {
+		t.Errorf("got name=%s strict=%v directed=%v", g.Name(), g.Strict(), g.Directed())
+	}
+	var sb strings.Builder
+	if err := g.WriteWithOptions(&sb, nil, zgr.WriteOptions{Order: zgr.InputOrder}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	exp := `strict digraph "my graph" {
+          rankdir=LR splines=ortho
+          "b" [shape=box, label="B"]
+          "a" [label="A", color=red]
+          "b" -> "a" [z=1 a=2]
+        }`
+	td := testutils.Check(sb.String(), exp)
+	if td != "" {
+		t.Errorf(td)
+	}
+
+	ug := zgr.NewGraph()
+	uins := `graph { "x" "y" "x" -- "y" }`
+	if err := ParseGraph(strings.NewReader(uins), ug); err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if ug.Directed() || ug.Name() != "" {
+		t.Errorf("got name=%q directed=%v", ug.Name(), ug.Directed())
+	}
+	if !ug.HasEdge(0, 1) {
+		t.Errorf("missing x -- y edge")
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	ins := `digraph G {
+          node [shape=box, height=0.25];
+          edge [color=red; style=dashed];
+          "a" [label="A"; tooltip="x"];
+          "b";
+          "a" -> "b" [weight=1, label="ab"];
+        }`
+	g := zgr.NewGraph()
+	if err := ParseGraph(strings.NewReader(ins), g); err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	var sb strings.Builder
+	opts := zgr.WriteOptions{
+		Order:        zgr.InputOrder,
+		Semicolons:   true,
+		Commas:       zgr.AllCommas,
+		EmitDefaults: true,
+	}
+	if err := g.WriteWithOptions(&sb, nil, opts); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	exp := `digraph G {
+          node [shape=box, height=0.25];
+          edge [color=red, style=dashed];
+          "a" [label="A", tooltip="x"];
+          "b" ;
+          "a" -> "b" [weight=1, label="ab"];
+        }`
+	td := testutils.Check(sb.String(), exp)
+	if td != "" {
+		t.Errorf(td)
+	}
+
+	// The rewritten graph should parse back to the same thing.
+	g2 := zgr.NewGraph()
+	if err := ParseGraph(strings.NewReader(sb.String()), g2); err != nil {
+		t.Fatalf("reparse: %v", err)
+	}
+	if g.String() != g2.String() {
+		t.Errorf("reparse mismatch:\n%s\nvs\n%s", g.String(), g2.String())
+	}
+}
+
+func TestLateDefaults(t *testing.T) {
+	// Default blocks only apply to the statements that follow them.
+	ins := `digraph G {
+          node [shape=box]
+          "a"
+          node [shape=oval, color=red]
+          "b"
+          "c" [color=blue]
+          "a" -> "b"
+          edge [style=dashed]
+          "b" -> "c"
+        }`
+	g := zgr.NewGraph()
+	if err := ParseGraph(strings.NewReader(ins), g); err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	var sb strings.Builder
+	opts := zgr.WriteOptions{Order: zgr.InputOrder, EmitDefaults: true}
+	if err := g.WriteWithOptions(&sb, nil, opts); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	exp := `digraph G {
+          node [shape=box]
+          "a"
+          "b" [shape=oval, color=red]
+          "c" [color=blue, shape=oval]
+          "a" -> "b"
+          "b" -> "c" [style=dashed]
+        }`
+	if td := testutils.Check(sb.String(), exp); td != "" {
+		t.Errorf(td)
+	}
+}
+
+func TestMalformed(t *testing.T) {
+	// Malformed input is reported as an error, not a panic.
+	inputs := []string{
+		`digraph G { "a" [label="x" and "y"] }`,
+		`digraph G { "a" -> }`,
+		`digraph G { "a" [label=] }`,
+		`digraph G { "a"`,
+		`digraph G { "a\" }`,
+		`graph`,
+		`digraph G { ] }`,
+	}
+	for _, ins := range inputs {
+		err := ParseGraph(strings.NewReader(ins), zgr.NewGraph())
+		if err == nil {
+			t.Errorf("expected error parsing %s", ins)
+		} else if !strings.HasPrefix(err.Error(), "error: line ") {
+			t.Errorf("parsing %s: unexpected error form %q", ins, err)
+		}
+	}
+}
+
+// BenchmarkReload compares reloading a graph from DOT with reloading
+// it from zgr's binary encoding.
+func BenchmarkReload(b *testing.B) {
+	const n = 20000
+	g := zgr.NewGraph()
+	for i := 0; i < n; i++ {
+		g.MakeNodeOrdered(fmt.Sprintf("\"n%d\"", i),
+			[]zgr.Attr{zgr.NewAttr("label", fmt.Sprintf("\"node %d\"", i)), zgr.NewAttr("shape", "box")})
+	}
+	for i := 0; i < n; i++ {
+		for _, j := range []int{i + 1, i * 7, i / 2} {
+			if j < n && j != i {
+				g.AddEdgeOrdered(fmt.Sprintf("\"n%d\"", i), fmt.Sprintf("\"n%d\"", j),
+					[]zgr.Attr{zgr.NewAttr("weight", "1")})
+			}
+		}
+	}
+	var sb strings.Builder
+	if err := g.Write(&sb, nil); err != nil {
+		b.Fatal(err)
+	}
+	dot := sb.String()
+	bin, err := g.MarshalBinary()
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Run("dot", func(b *testing.B) {
+		b.SetBytes(int64(len(dot)))
+		for i := 0; i < b.N; i++ {
+			if err := ParseGraph(strings.NewReader(dot), zgr.NewGraph()); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("binary", func(b *testing.B) {
+		b.SetBytes(int64(len(bin)))
+		for i := 0; i < b.N; i++ {
+			if err := zgr.NewGraph().UnmarshalBinary(bin); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,121 @@
+package grprune
+
+import (
+	"errors"
+	"fmt"
+	"iter"
+	"regexp"
+	"strings"
+
+	"github.com/thanm/grvutils/zgr"
+)
+
+// Edge filters restrict the walks to edges whose attributes match. A
+// filter is a comma-separated list of terms, all of which must hold:
+//
+//	KEY=VAL    the edge has attribute KEY with value VAL
+//	KEY!=VAL   the edge does not have attribute KEY with value VAL
+//	KEY~RE     the value of KEY matches the regular expression RE
+//	KEY!~RE    the value of KEY does not match RE
+//
+// Edge defaults apply, values are compared without their DOT quotes,
+// and a missing attribute has the empty string as its value. So
+// "style!=dashed" follows every edge not drawn dashed, and "kind=call"
+// only edges with kind=call. Since ',' separates terms, a value or
+// regular expression can't contain one (so "{1,2}" can't be used).
+
+type edgeTerm struct {
+	key, op, val string
+	re           *regexp.Regexp
+}
+
+type edgeFilter []edgeTerm
+
+func parseEdgeFilter(s string) (edgeFilter, error) {
+	var f edgeFilter
+	for _, term := range strings.Split(s, ",") {
+		if term == "" {
+			continue
+		}
+		i := strings.IndexAny(term, "=~!")
+		if i <= 0 {
+			return nil, errors.New(fmt.Sprintf("error: edge filter term '%s' should have the form KEY=VAL, KEY!=VAL, KEY~RE or KEY!~RE", term))
+		}
+		t := edgeTerm{key: term[:i]}
+		rest := term[i:]
+		for _, op := range []string{"!=", "!~", "=", "~"} {
+			if strings.HasPrefix(rest, op) {
+				t.op, t.val = op, rest[len(op):]
+				break
+			}
+		}
+		switch t.op {
+		case "":
+			return nil, errors.New(fmt.Sprintf("error: bad operator in edge filter term '%s'", term))
+		case "~", "!~":
+			re, err := regexp.Compile(t.val)
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("error: bad pattern in edge filter term '%s': %v", term, err))
+			}
+			t.re = re
+		}
+		f = append(f, t)
+	}
+	return f, nil
+}
+
+// match reports whether edge eidx satisfies every term of f.
+func (f edgeFilter) match(g zgr.Topology, eidx uint32) bool {
+	for _, t := range f {
+		v, _ := g.EdgeAttr(eidx, t.key)
+		v = zgr.Unquote(v)
+		var ok bool
+		switch t.op {
+		case "=":
+			ok = v == t.val
+		case "!=":
+			ok = v != t.val
+		case "~":
+			ok = t.re.MatchString(v)
+		case "!~":
+			ok = !t.re.MatchString(v)
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// succs and preds return functions giving the successors and
+// predecessors of a node along the edges that satisfy f. With an
+// empty filter they are just g's own iterators.
+func (f edgeFilter) succs(g zgr.Topology) func(uint32) iter.Seq[uint32] {
+	if len(f) == 0 {
+		return g.SuccessorIndices
+	}
+	return func(v uint32) iter.Seq[uint32] {
+		return func(yield func(uint32) bool) {
+			for e, w := range g.OutEdgeIndices(v) {
+				if f.match(g, e) && !yield(w) {
+					return
+				}
+			}
+		}
+	}
+}
+
+func (f edgeFilter) preds(g zgr.Topology) func(uint32) iter.Seq[uint32] {
+	if len(f) == 0 {
+		return g.PredecessorIndices
+	}
+	return func(v uint32) iter.Seq[uint32] {
+		return func(yield func(uint32) bool) {
+			for e, w := range g.InEdgeIndices(v) {
+				if f.match(g, e) && !yield(w) {
+					return
+				}
+			}
+		}
+	}
+}
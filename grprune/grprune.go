@@ -1,104 +1,613 @@
 package grprune
 
 import (
+	"cmp"
 	"errors"
 	"fmt"
 	"io"
+	"iter"
+	"maps"
+	"slices"
 	"strings"
 
 	"github.com/thanm/grvutils/zgr"
 )
 
-func walk(g *zgr.Graph, node *zgr.Node, depth int, dcutoff int, inc map[uint32]bool, excl map[uint32]bool) {
+// Options controls how a graph is pruned.
+type Options struct {
+	// Roots select the nodes to start from: node IDs (without DOT
+	// quotes) or the label and attribute selectors described in
+	// select.go. The slice is the union of the slices for each root.
+	Roots []string
 
-	// Bail now if on exclude list
-	if _, ok := excl[g.GetNodeIndex(node)]; ok {
-		return
-	}
+	// Mode is one of "fwd", "bwd" or "both".
+	Mode string
 
-	// Mark this node for inclusion
-	inc[g.GetNodeIndex(node)] = true
+	// FwdDepth and BwdDepth are the maximum number of steps to walk
+	// forward and backward from each root; Unlimited (or any negative
+	// value) removes the limit.
+	FwdDepth, BwdDepth int
 
-	// Stop here if we've reached the cutoff
-	if depth >= dcutoff {
-		return
+	// Exclude selects nodes to leave out of the slice, in the same
+	// way as Roots; the walk does not continue through them unless
+	// Bypass is set.
+	Exclude []string
+
+	// Bypass makes the walk pass through excluded nodes (counting
+	// them as ordinary steps) instead of stopping at them. The
+	// excluded nodes are still left out, and each kept node is joined
+	// by a dashed edge to the kept nodes it reached only through
+	// excluded ones, so that removing a hub does not disconnect the
+	// slice. A path through excluded nodes only counts if a single
+	// walk may follow all of its edges (see FwdEdges and BwdEdges).
+	Bypass bool
+
+	// To, if set, selects target nodes in the same way as Roots, and
+	// the slice holds exactly the nodes on some path from a root to a
+	// target: those reachable forward from the root and backward from
+	// the target. FwdDepth then bounds the length of those paths, and
+	// Mode and BwdDepth are ignored.
+	To []string
+
+	// FwdEdges and BwdEdges, if set, are edge filters (described in
+	// edgefilter.go) restricting the forward and backward walks to
+	// the edges that match. A path slice (see To) uses FwdEdges in
+	// both directions, since it only follows forward paths.
+	FwdEdges, BwdEdges string
+
+	// AllMatches lets a root or exclude selector match several nodes,
+	// all of which are used. Otherwise such a selector is an error,
+	// reported along with the candidate nodes.
+	AllMatches bool
+
+	// Frontier selects how nodes at the depth limit whose neighbors
+	// were cut off are shown; see FrontierMode. It has no effect on
+	// path slices (see To).
+	Frontier FrontierMode
+
+	// ColorRoots colors each node with a color picked for the root
+	// whose walk reached it. Nodes reached from more than one root
+	// are left alone.
+	ColorRoots bool
+}
+
+// FrontierMode says how Slice.Graph marks frontier nodes: nodes at
+// which a walk stopped because of the depth limit, and which have
+// neighbors (in the direction of that walk) outside the slice.
+type FrontierMode uint8
+
+const (
+	// FrontierNone leaves frontier nodes alone.
+	FrontierNone FrontierMode = 0
+	// FrontierAnnotate draws frontier nodes dashed, with an external
+	// label giving the number of hidden successors/predecessors.
+	FrontierAnnotate FrontierMode = 1
+	// FrontierPlaceholders attaches a dashed "... N more" node to each
+	// frontier node in place of its hidden neighbors.
+	FrontierPlaceholders FrontierMode = 2
+)
+
+// Source is a graph that can be pruned and written: either a
+// *zgr.Graph or a *zgr.Frozen. Pruning never modifies the graph, so a
+// *zgr.Frozen may be pruned by many goroutines at once; see the notes on
+// zgr.Graph for the conditions under which the same holds for it.
+type Source interface {
+	zgr.Topology
+	WriteWithOptions(w io.Writer, toinclude map[uint32]bool, opts zgr.WriteOptions) error
+	Subgraph(include map[uint32]bool) *zgr.Graph
+}
+
+// Slice is the result of pruning a graph.
+type Slice struct {
+	opts Options
+
+	// Include holds the indices of the nodes in the slice.
+	Include map[uint32]bool
+
+	// Roots holds the indices of the root nodes, in the order given.
+	// A selector matching several nodes contributes each of them.
+	Roots []uint32
+
+	// Reached records, for each node in the slice, the positions in
+	// Roots of the roots whose walks reached it.
+	Reached map[uint32][]int
+
+	// FwdDist and BwdDist record the shortest distance from the
+	// nearest root to each node reached by the forward and backward
+	// walks respectively. A path slice has no backward walk, so its
+	// BwdDist is empty.
+	FwdDist, BwdDist map[uint32]int
+
+	// TargetDist records, for a path slice, the shortest distance
+	// from each node in the slice to the nearest target.
+	TargetDist map[uint32]int
+
+	// Targets holds the indices of the nodes selected by Options.To.
+	Targets []uint32
+
+	// HiddenSuccs and HiddenPreds give, for each frontier node, the
+	// number of its successors (for the forward walk) or predecessors
+	// (for the backward walk) left out of the slice by the depth
+	// limit. Excluded nodes are not counted.
+	HiddenSuccs, HiddenPreds map[uint32]int
+
+	// Bypassed holds the edges added for Options.Bypass, as (source,
+	// sink) index pairs sorted by source and then sink.
+	Bypassed [][2]uint32
+}
+
+// Unlimited as a depth removes the limit on how far a walk goes.
+const Unlimited = -1
+
+// rootColors is the palette used for Options.ColorRoots; roots beyond
+// its length reuse colors from the start.
+var rootColors = []string{"red", "blue", "green4", "darkorange", "purple",
+	"cyan4", "magenta", "brown", "gold3", "deeppink"}
+
+// bfs walks breadth-first from root along the edges given by next
+// (successors for a forward walk, predecessors for a backward one),
+// and returns the shortest distance from root to each node reached
+// within cutoff steps. A negative cutoff means no limit. Excluded
+// nodes are neither reached nor walked through. Each node and edge is
+// visited at most once, so a walk costs O(V+E).
+func bfs(next func(uint32) iter.Seq[uint32], root uint32, cutoff int, excl map[uint32]bool) map[uint32]int {
+	dist := make(map[uint32]int)
+	if excl[root] {
+		return dist
+	}
+	dist[root] = 0
+	queue := []uint32{root}
+	for head := 0; head < len(queue); head++ {
+		v := queue[head]
+		d := dist[v]
+		if cutoff >= 0 && d >= cutoff {
+			continue
+		}
+		for w := range next(v) {
+			if _, seen := dist[w]; seen || excl[w] {
+				continue
+			}
+			dist[w] = d + 1
+			queue = append(queue, w)
+		}
 	}
+	return dist
+}
 
-	// Visit out-edge targets
-	outs := g.GetEdges(node)
-	for _, eid := range outs {
-		e := g.GetEdge(eid)
-		_, sinkid := g.GetEndpoints(e)
-		sink := g.GetNode(sinkid)
-		walk(g, sink, depth+1, dcutoff, inc, excl)
+// mergeDist folds the distances in from into into, keeping the
+// smaller distance for nodes present in both.
+func mergeDist(into, from map[uint32]int) {
+	for idx, d := range from {
+		if od, ok := into[idx]; !ok || d < od {
+			into[idx] = d
+		}
 	}
 }
 
-func makeExcludeSet(g *zgr.Graph, toex string, excl map[uint32]bool) error {
-	ids := strings.Split(toex, ",")
-	for _, id := range ids {
+func makeExcludeSet(g zgr.Topology, toex []string, all bool, excl map[uint32]bool) error {
+	for _, id := range toex {
 		if id == "" {
 			continue
 		}
-		quid := fmt.Sprintf("\"%s\"", id)
-		en := g.LookupNode(quid)
-		if en == nil {
-			s := fmt.Sprintf("error: unable to locate exclude node '%s'", id)
-			return errors.New(s)
+		nodes, err := selectNodes(g, id, "exclude", all)
+		if err != nil {
+			return err
+		}
+		for _, en := range nodes {
+			excl[en] = true
 		}
-		excl[g.GetNodeIndex(en)] = true
 	}
 	return nil
 }
 
-func getPrunedSet(g *zgr.Graph, rootid string, mode string, depth int, toex string) (error, map[uint32]bool) {
+// splitList splits a comma-separated list of node IDs.
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
 
-	// Locate the root node with the specified ID.
-	quid := fmt.Sprintf("\"%s\"", rootid)
-	rn := g.LookupNode(quid)
-	if rn == nil {
-		s := fmt.Sprintf("error: unable to locate root node '%s'", rootid)
-		return errors.New(s), nil
+// Prune computes the slice of g selected by opts.
+//
+// Prune makes one breadth-first walk per root and direction, and one per
+// target for a path slice, each costing O(V+E). Counting the neighbors
+// hidden beyond the depth limit adds one more pass over the slice's edges.
+// With Bypass, each of the K kept nodes also searches through the
+// excluded nodes, for O((R+T+K)·(V+E)) in all with R roots and T
+// targets; without it, the bound is O((R+T)·(V+E)).
+func Prune(g zgr.Topology, opts Options) (*Slice, error) {
+	if len(opts.Roots) == 0 {
+		return nil, errors.New("error: no root nodes specified")
+	}
+	if len(opts.To) == 0 && opts.Mode != "both" && opts.Mode != "fwd" && opts.Mode != "bwd" {
+		return nil, errors.New(fmt.Sprintf("error: illegal prune mode '%s'", opts.Mode))
+	}
+	s := &Slice{
+		opts:        opts,
+		Include:     make(map[uint32]bool),
+		Reached:     make(map[uint32][]int),
+		FwdDist:     make(map[uint32]int),
+		BwdDist:     make(map[uint32]int),
+		TargetDist:  make(map[uint32]int),
+		HiddenSuccs: make(map[uint32]int),
+		HiddenPreds: make(map[uint32]int),
 	}
 
-	// This map is going to hold the indices of the nodes we want to
-	// include in the final slice.
-	include := make(map[uint32]bool)
+	// Locate the root nodes with the specified IDs.
+	for _, id := range opts.Roots {
+		nodes, err := selectNodes(g, id, "root", opts.AllMatches)
+		if err != nil {
+			return nil, err
+		}
+		s.Roots = append(s.Roots, nodes...)
+	}
 
 	// This map is going to hold the indices of the nodes we want to
 	// exclude from the final slice.
 	exclude := make(map[uint32]bool)
-	if err := makeExcludeSet(g, toex, exclude); err != nil {
-		return err, nil
+	if err := makeExcludeSet(g, opts.Exclude, opts.AllMatches, exclude); err != nil {
+		return nil, err
+	}
+
+	ff, err := parseEdgeFilter(opts.FwdEdges)
+	if err != nil {
+		return nil, err
 	}
+	bf, err := parseEdgeFilter(opts.BwdEdges)
+	if err != nil {
+		return nil, err
+	}
+	succs, preds := ff.succs(g), bf.preds(g)
 
-	// Forward walk from root
-	if mode == "both" || mode == "fwd" {
-		walk(g, rn, 0, depth, include, exclude)
+	// With Bypass, the walks go through the excluded nodes, which
+	// are removed from the slice afterwards.
+	walkExcl := exclude
+	if opts.Bypass {
+		walkExcl = nil
 	}
 
-	// Backwards walk from root
-	if mode == "both" || mode == "bwd" {
-		tg := g.Transpose()
-		quid := fmt.Sprintf("\"%s\"", rootid)
-		rn := tg.LookupNode(quid)
-		walk(tg, rn, 0, depth, include, exclude)
+	if len(opts.To) != 0 {
+		if err := s.paths(g, ff, walkExcl); err != nil {
+			return nil, err
+		}
+		if opts.Bypass {
+			s.bypass(g, ff.succs(g), nil, exclude)
+		}
+		return s, nil
 	}
 
-	return nil, include
+	for ri, rn := range s.Roots {
+		reached := make(map[uint32]bool)
+
+		// Forward walk from root
+		if opts.Mode == "both" || opts.Mode == "fwd" {
+			dist := bfs(succs, rn, opts.FwdDepth, walkExcl)
+			for idx := range dist {
+				reached[idx] = true
+			}
+			mergeDist(s.FwdDist, dist)
+		}
+
+		// Backwards walk from root
+		if opts.Mode == "both" || opts.Mode == "bwd" {
+			dist := bfs(preds, rn, opts.BwdDepth, walkExcl)
+			for idx := range dist {
+				reached[idx] = true
+			}
+			mergeDist(s.BwdDist, dist)
+		}
+
+		for idx := range reached {
+			s.Include[idx] = true
+			s.Reached[idx] = append(s.Reached[idx], ri)
+		}
+	}
+	if opts.Bypass {
+		// A bypassed chain has to be one that the walk making it could
+		// follow.
+		var fnext, bnext func(uint32) iter.Seq[uint32]
+		if opts.Mode == "both" || opts.Mode == "fwd" {
+			fnext = succs
+		}
+		if opts.Mode == "both" || opts.Mode == "bwd" {
+			bnext = preds
+		}
+		s.bypass(g, fnext, bnext, exclude)
+	}
+	s.countHidden(succs, s.FwdDist, opts.FwdDepth, exclude, s.HiddenSuccs)
+	s.countHidden(preds, s.BwdDist, opts.BwdDepth, exclude, s.HiddenPreds)
+	return s, nil
 }
 
-func PruneGraph(g *zgr.Graph, rootid string, mode string, depth int, exclude string, w io.Writer) error {
-	// Collect IDs of nodes to write
-	err, include := getPrunedSet(g, rootid, mode, depth, exclude)
+// bypass removes the excluded nodes from the slice, and records an
+// edge from each kept node u to each kept node v that u reaches
+// through one or more excluded nodes, unless g already has an edge
+// from u to v. A chain has to be one a single walk could follow: for
+// the forward walk, it runs from a node that walk reached along the
+// edges given by succs; for the backward walk, it runs back from a
+// node that walk reached along the edges given by preds. Either is
+// nil if that walk wasn't made. The existing edge is checked for among
+// all of g's edges, not just the filtered ones, since the written
+// slice keeps every edge between its nodes.
+func (s *Slice) bypass(g zgr.Topology, succs, preds func(uint32) iter.Seq[uint32], exclude map[uint32]bool) {
+	for idx := range exclude {
+		delete(s.Include, idx)
+		delete(s.Reached, idx)
+		delete(s.FwdDist, idx)
+		delete(s.BwdDist, idx)
+		delete(s.TargetDist, idx)
+	}
+	// reach returns the kept nodes that u reaches along next through
+	// one or more excluded nodes, leaving out u's neighbors in g (as
+	// given by all).
+	reach := func(u uint32, next, all func(uint32) iter.Seq[uint32]) []uint32 {
+		direct := make(map[uint32]bool)
+		for w := range all(u) {
+			direct[w] = true
+		}
+		var stack []uint32
+		seen := make(map[uint32]bool)
+		for w := range next(u) {
+			if exclude[w] && !seen[w] {
+				seen[w] = true
+				stack = append(stack, w)
+			}
+		}
+		var res []uint32
+		for len(stack) != 0 {
+			x := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			for w := range next(x) {
+				switch {
+				case exclude[w]:
+					if !seen[w] {
+						seen[w] = true
+						stack = append(stack, w)
+					}
+				case s.Include[w] && w != u && !direct[w]:
+					direct[w] = true
+					res = append(res, w)
+				}
+			}
+		}
+		return res
+	}
+	found := make(map[[2]uint32]bool)
+	if succs != nil {
+		for u := range s.FwdDist {
+			for _, v := range reach(u, succs, g.SuccessorIndices) {
+				found[[2]uint32{u, v}] = true
+			}
+		}
+	}
+	if preds != nil {
+		for v := range s.BwdDist {
+			for _, u := range reach(v, preds, g.PredecessorIndices) {
+				found[[2]uint32{u, v}] = true
+			}
+		}
+	}
+	s.Bypassed = slices.SortedFunc(maps.Keys(found), func(a, b [2]uint32) int {
+		if c := cmp.Compare(a[0], b[0]); c != 0 {
+			return c
+		}
+		return cmp.Compare(a[1], b[1])
+	})
+}
+
+// countHidden records in hidden the number of neighbors (as given by
+// next) outside the slice for each node that a walk reached at exactly
+// the depth limit, and so did not expand.
+func (s *Slice) countHidden(next func(uint32) iter.Seq[uint32], dist map[uint32]int, cutoff int, exclude map[uint32]bool, hidden map[uint32]int) {
+	if cutoff < 0 {
+		return
+	}
+	for idx, d := range dist {
+		if d != cutoff {
+			continue
+		}
+		k := 0
+		for nb := range next(idx) {
+			if !s.Include[nb] && !exclude[nb] {
+				k++
+			}
+		}
+		if k != 0 {
+			hidden[idx] = k
+		}
+	}
+}
+
+// paths fills in s with the nodes on paths from the roots to the
+// targets selected by Options.To.
+func (s *Slice) paths(g zgr.Topology, f edgeFilter, exclude map[uint32]bool) error {
+	opts := s.opts
+	for _, id := range opts.To {
+		nodes, err := selectNodes(g, id, "target", opts.AllMatches)
+		if err != nil {
+			return err
+		}
+		s.Targets = append(s.Targets, nodes...)
+	}
+
+	// A node v is on a path of length at most maxlen from root r to
+	// some target if dist(r, v) + dist(v, target) <= maxlen.
+	maxlen := opts.FwdDepth
+	for _, tn := range s.Targets {
+		mergeDist(s.TargetDist, bfs(f.preds(g), tn, maxlen, exclude))
+	}
+	for ri, rn := range s.Roots {
+		dist := bfs(f.succs(g), rn, maxlen, exclude)
+		for idx, d := range dist {
+			td, ok := s.TargetDist[idx]
+			if !ok || (maxlen >= 0 && d+td > maxlen) {
+				continue
+			}
+			s.Include[idx] = true
+			s.Reached[idx] = append(s.Reached[idx], ri)
+			if od, ok := s.FwdDist[idx]; !ok || d < od {
+				s.FwdDist[idx] = d
+			}
+		}
+	}
+	maps.DeleteFunc(s.TargetDist, func(idx uint32, _ int) bool { return !s.Include[idx] })
+	return nil
+}
+
+// Graph returns the slice as a new graph, with any decorations
+// requested in the options applied. g must be the graph passed to
+// Prune.
+func (s *Slice) Graph(g Source) *zgr.Graph {
+	sg := g.Subgraph(s.Include)
+	if s.opts.ColorRoots {
+		for idx, roots := range s.Reached {
+			if len(roots) != 1 {
+				continue
+			}
+			n := sg.LookupNode(g.NodeID(idx))
+			sg.SetNodeAttr(n, "color", rootColors[roots[0]%len(rootColors)])
+		}
+	}
+	for _, e := range s.Bypassed {
+		sg.AddEdgeOrdered(g.NodeID(e[0]), g.NodeID(e[1]), dashedAttrs)
+	}
+	switch s.opts.Frontier {
+	case FrontierAnnotate:
+		s.annotateFrontier(g, sg)
+	case FrontierPlaceholders:
+		s.addPlaceholders(g, sg)
+	}
+	return sg
+}
+
+// decorated reports whether Graph has anything to add to the plain
+// subgraph.
+func (s *Slice) decorated() bool {
+	return s.opts.ColorRoots || s.opts.Frontier != FrontierNone || len(s.Bypassed) != 0
+}
+
+func (s *Slice) frontier() []uint32 {
+	var res []uint32
+	for idx := range s.HiddenSuccs {
+		res = append(res, idx)
+	}
+	for idx := range s.HiddenPreds {
+		if _, ok := s.HiddenSuccs[idx]; !ok {
+			res = append(res, idx)
+		}
+	}
+	slices.Sort(res)
+	return res
+}
+
+func (s *Slice) annotateFrontier(g Source, sg *zgr.Graph) {
+	for _, idx := range s.frontier() {
+		var parts []string
+		if k := s.HiddenSuccs[idx]; k != 0 {
+			parts = append(parts, fmt.Sprintf("+%d out", k))
+		}
+		if k := s.HiddenPreds[idx]; k != 0 {
+			parts = append(parts, fmt.Sprintf("+%d in", k))
+		}
+		n := sg.LookupNode(g.NodeID(idx))
+		style := "dashed"
+		if old, ok := g.NodeAttr(idx, "style"); ok && zgr.Unquote(old) != "" {
+			style = zgr.Unquote(old) + ",dashed"
+		}
+		sg.SetNodeAttr(n, "style", zgr.Quote(style))
+		sg.SetNodeAttr(n, "xlabel", zgr.Quote(strings.Join(parts, ", ")))
+	}
+}
+
+// dashedAttrs are given to the synthetic edges added by Graph, and to
+// the placeholder nodes for FrontierPlaceholders.
+var dashedAttrs = []zgr.Attr{zgr.NewAttr("style", "dashed")}
+
+func (s *Slice) addPlaceholders(g Source, sg *zgr.Graph) {
+	mkid := func(base string) string {
+		id := zgr.Quote(base)
+		for i := 1; sg.LookupNode(id) != nil; i++ {
+			id = zgr.Quote(fmt.Sprintf("%s_%d", base, i))
+		}
+		return id
+	}
+	for _, idx := range s.frontier() {
+		id := g.NodeID(idx)
+		for _, dir := range []struct {
+			k   int
+			tag string
+		}{{s.HiddenSuccs[idx], "succs"}, {s.HiddenPreds[idx], "preds"}} {
+			if dir.k == 0 {
+				continue
+			}
+			pid := mkid(fmt.Sprintf("%s...%s", zgr.Unquote(id), dir.tag))
+			attrs := append([]zgr.Attr{
+				zgr.NewAttr("label", zgr.Quote(fmt.Sprintf("… %d more", dir.k))),
+				zgr.NewAttr("shape", "plaintext"),
+			}, dashedAttrs...)
+			sg.MakeNodeOrdered(pid, attrs)
+			if dir.tag == "succs" {
+				sg.AddEdgeOrdered(id, pid, dashedAttrs)
+			} else {
+				sg.AddEdgeOrdered(pid, id, dashedAttrs)
+			}
+		}
+	}
+}
+
+// legacyOptions converts the arguments of the original API into
+// Options. Its root and exclude IDs are literal node IDs, so they are
+// given the "id:" prefix to keep an ID such as "label:x" from being
+// read as a selector.
+func legacyOptions(rootid string, mode string, depth int, exclude string) Options {
+	ids := splitList(exclude)
+	for i, id := range ids {
+		ids[i] = "id:" + id
+	}
+	return Options{
+		Roots:    []string{"id:" + rootid},
+		Mode:     mode,
+		FwdDepth: depth,
+		BwdDepth: depth,
+		Exclude:  ids,
+	}
+}
+
+// PrunedSet returns the indices of the nodes that PruneGraph would
+// write, for callers that want to post-process the slice themselves.
+// g may be a zgr.Graph or a zgr.Frozen; for the latter, the slice can
+// be extracted with Frozen.Subgraph.
+func PrunedSet(g zgr.Topology, rootid string, mode string, depth int, exclude string) (map[uint32]bool, error) {
+	s, err := Prune(g, legacyOptions(rootid, mode, depth, exclude))
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return s.Include, nil
+}
 
-	// Do the write
-	if err := g.Write(w, include); err != nil {
+func PruneGraph(g Source, rootid string, mode string, depth int, exclude string, w io.Writer) error {
+	return PruneGraphWithOptions(g, rootid, mode, depth, exclude, w, zgr.WriteOptions{})
+}
+
+// PruneGraphWithOptions is like PruneGraph, but writes the pruned
+// graph using the specified writer options.
+func PruneGraphWithOptions(g Source, rootid string, mode string, depth int, exclude string, w io.Writer, wopts zgr.WriteOptions) error {
+	return WritePruned(g, legacyOptions(rootid, mode, depth, exclude), w, wopts)
+}
+
+// WritePruned prunes g as specified by opts and writes the result to w
+// in DOT format.
+func WritePruned(g Source, opts Options, w io.Writer, wopts zgr.WriteOptions) error {
+	// Collect IDs of nodes to write
+	s, err := Prune(g, opts)
+	if err != nil {
 		return err
 	}
 
-	return nil
+	// Without decorations there's no need to copy the slice out.
+	if !s.decorated() {
+		return g.WriteWithOptions(w, s.Include, wopts)
+	}
+	return s.Graph(g).WriteWithOptions(w, nil, wopts)
 }
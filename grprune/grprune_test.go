@@ -1,10 +1,12 @@
 package grprune
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/thanm/grvutils/grparser"
@@ -25,6 +27,30 @@ func doparse(ins string) (*zgr.Graph, error) {
 	return g, nil
 }
 
+// distNodes returns the IDs of the nodes of g in m, in index order,
+// with their distances.
+func distNodes(g *zgr.Graph, m map[uint32]int) string {
+	var res []string
+	for n := range g.Nodes() {
+		if d, ok := m[n.Idx()]; ok {
+			res = append(res, fmt.Sprintf("%s=%d", n.Id(), d))
+		}
+	}
+	return strings.Join(res, " ")
+}
+
+// sliceNodes returns the IDs of the nodes of g in slice s, in index
+// order.
+func sliceNodes(g *zgr.Graph, s *Slice) string {
+	var res []string
+	for n := range g.Nodes() {
+		if s.Include[n.Idx()] {
+			res = append(res, n.Id())
+		}
+	}
+	return strings.Join(res, " ")
+}
+
 const testgraph = `digraph Y {
    "a" [label="A"]
    "b" [label="B"]
@@ -146,3 +172,659 @@ func TestBasic(t *testing.T) {
 		}
 	}
 }
+
+func TestFrozen(t *testing.T) {
+	graph, err := doparse(testgraph)
+	if err != nil {
+		t.Fatalf("parsing initial graph: %v", err)
+	}
+	frozen := graph.Freeze()
+	inputs := []testcase{
+		{"both", "", 1},
+		{"fwd", "", 2},
+		{"bwd", "e", 2},
+		{"both", "a,d", 3},
+	}
+	for _, tc := range inputs {
+		want, err := PrunedSet(graph, "c", tc.mode, tc.depth, tc.exclude)
+		if err != nil {
+			t.Fatalf("%v: %v", tc, err)
+		}
+		got, err := PrunedSet(frozen, "c", tc.mode, tc.depth, tc.exclude)
+		if err != nil {
+			t.Fatalf("%v: frozen: %v", tc, err)
+		}
+		if d := graph.Subgraph(want).Diff(frozen.Subgraph(got)); !d.Empty() {
+			t.Errorf("%v: frozen slice differs from graph slice", tc)
+		}
+	}
+	if _, err := PrunedSet(frozen, "nosuch", "both", 1, ""); err == nil {
+		t.Errorf("expected error for unknown root")
+	}
+}
+
+// TestConcurrentPrune slices the same graph from many goroutines; it
+// is mainly useful under -race.
+func TestConcurrentPrune(t *testing.T) {
+	graph, err := doparse(testgraph)
+	if err != nil {
+		t.Fatalf("parsing initial graph: %v", err)
+	}
+	roots := []string{"a", "b", "c", "d", "e", "f", "g"}
+	for _, src := range []Source{graph, graph.Freeze()} {
+		want := make(map[string]string)
+		for _, r := range roots {
+			var sb strings.Builder
+			if err := PruneGraph(src, r, "both", 2, "", &sb); err != nil {
+				t.Fatal(err)
+			}
+			want[r] = sb.String()
+		}
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 20; j++ {
+					r := roots[(i+j)%len(roots)]
+					var sb strings.Builder
+					if err := PruneGraph(src, r, "both", 2, "", &sb); err != nil {
+						t.Error(err)
+						return
+					}
+					if sb.String() != want[r] {
+						t.Errorf("root %s: concurrent prune gave different output", r)
+					}
+				}
+			}(i)
+		}
+		wg.Wait()
+	}
+}
+
+func TestMultipleRoots(t *testing.T) {
+	graph, err := doparse(testgraph)
+	if err != nil {
+		t.Fatalf("parsing initial graph: %v", err)
+	}
+	opts := Options{
+		Roots:      []string{"a", "e"},
+		Mode:       "fwd",
+		FwdDepth:   1,
+		ColorRoots: true,
+	}
+	var sb strings.Builder
+	if err := WritePruned(graph, opts, &sb, zgr.WriteOptions{Order: zgr.InputOrder}); err != nil {
+		t.Fatal(err)
+	}
+	expected := `digraph Y {
+"a"  [label="A", color=red]
+"e"  [label="E", color=blue]
+"f"  [label="F", color=blue]
+"g"  [label="G", color=red]
+"e" -> "f" [b=1]
+"a" -> "g" [b=1]
+}
+`
+	if td := testutils.Check(sb.String(), expected); td != "" {
+		t.Errorf(td)
+	}
+
+	// The union of single-root slices, with shared nodes recorded.
+	opts = Options{Roots: []string{"c", "g"}, Mode: "bwd", BwdDepth: 2}
+	s, err := Prune(graph, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range opts.Roots {
+		single, _ := PrunedSet(graph, r, "bwd", 2, "")
+		for idx := range single {
+			if !s.Include[idx] {
+				t.Errorf("node %s from root %s missing from union", graph.NodeID(idx), r)
+			}
+		}
+	}
+	a, _ := graph.LookupIndex(`"a"`)
+	if got := fmt.Sprint(s.Reached[a]); got != "[0 1]" {
+		t.Errorf("node a reached by roots %s, want [0 1]", got)
+	}
+
+	if _, err := Prune(graph, Options{Mode: "fwd"}); err == nil {
+		t.Errorf("expected error with no roots")
+	}
+	if _, err := Prune(graph, Options{Roots: []string{"a", "zz"}, Mode: "fwd"}); err == nil {
+		t.Errorf("expected error for unknown root")
+	}
+}
+
+func TestSelectors(t *testing.T) {
+	const ins = `digraph S {
+   "N1" [label="main"]
+   "N2" [label="foo::bar", kind=fn]
+   "N3" [label="foo::baz", kind=fn]
+   "N4" [label="qux"]
+   "id:x" [label="odd"]
+   "N1" -> "N2"
+   "N1" -> "N3"
+   "N3" -> "N4"
+   "N4" -> "id:x"
+ }`
+	graph, err := doparse(ins)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		roots, exclude []string
+		all            bool
+		want           string
+	}{
+		{[]string{"label:main"}, nil, false, `"N1" "N2" "N3"`},
+		{[]string{"labelre:baz$"}, nil, false, `"N1" "N3" "N4"`},
+		{[]string{"idre:^N4"}, []string{"label:foo::baz"}, false, `"N4" "id:x"`},
+		{[]string{"attr:kind=fn"}, nil, true, `"N1" "N2" "N3" "N4"`},
+		{[]string{"id:id:x"}, nil, false, `"N4" "id:x"`},
+		{[]string{"label:main"}, []string{"labelre:^foo"}, true, `"N1"`},
+	}
+	for _, c := range cases {
+		opts := Options{Roots: c.roots, Exclude: c.exclude, Mode: "both", FwdDepth: 1, BwdDepth: 1, AllMatches: c.all}
+		s, err := Prune(graph, opts)
+		if err != nil {
+			t.Errorf("%v: %v", c.roots, err)
+			continue
+		}
+		if got := sliceNodes(graph, s); got != c.want {
+			t.Errorf("%v excluding %v: got %s want %s", c.roots, c.exclude, got, c.want)
+		}
+	}
+
+	errs := []struct {
+		root, want string
+	}{
+		{"labelre:^foo", "ambiguous, matching 2 nodes:\n  N2 (label \"foo::bar\")\n  N3 (label \"foo::baz\")"},
+		{"main", "nodes labeled 'main' (use label:main):\n  N1"},
+		{"label:nosuch", "no node matches root selector"},
+		{"labelre:(", "bad pattern"},
+		{"attr:kind", "attr:KEY=VALUE"},
+	}
+	for _, e := range errs {
+		_, err := Prune(graph, Options{Roots: []string{e.root}, Mode: "fwd"})
+		if err == nil || !strings.Contains(err.Error(), e.want) {
+			t.Errorf("root %s: got error %v, want one containing %q", e.root, err, e.want)
+		}
+	}
+
+	// Selectors work the same way on a frozen graph.
+	s, err := Prune(graph.Freeze(), Options{Roots: []string{"labelre:baz$"}, Mode: "both", FwdDepth: 1, BwdDepth: 1})
+	if err != nil || sliceNodes(graph, s) != `"N1" "N3" "N4"` {
+		t.Errorf("frozen: got %v, %v", s, err)
+	}
+
+	// The original API takes literal IDs, even ones that look like
+	// selectors.
+	inc, err := PrunedSet(graph, "id:x", "both", 1, "N4")
+	if err != nil || len(inc) != 1 {
+		t.Errorf("PrunedSet of id:x: got %v, %v", inc, err)
+	}
+	if _, err := PrunedSet(graph, "label:main", "both", 1, ""); err == nil ||
+		!strings.Contains(err.Error(), "unable to locate root node 'label:main'") {
+		t.Errorf("PrunedSet of label:main: got error %v", err)
+	}
+}
+
+func TestDepths(t *testing.T) {
+	graph, err := doparse(testgraph)
+	if err != nil {
+		t.Fatalf("parsing initial graph: %v", err)
+	}
+	// testgraph is a single cycle c -> d -> e -> f -> c plus the
+	// cycle c -> b -> a -> g -> c.
+	cases := []struct {
+		fwd, bwd int
+		want     string
+	}{
+		{1, 0, `"b" "c" "d"`},
+		{0, 1, `"c" "f" "g"`},
+		{2, 1, `"a" "b" "c" "d" "e" "f" "g"`},
+		{1, 2, `"a" "b" "c" "d" "e" "f" "g"`},
+		{0, 0, `"c"`},
+		{Unlimited, 0, `"a" "b" "c" "d" "e" "f" "g"`},
+	}
+	for _, c := range cases {
+		opts := Options{Roots: []string{"c"}, Mode: "both", FwdDepth: c.fwd, BwdDepth: c.bwd}
+		s, err := Prune(graph, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := sliceNodes(graph, s); got != c.want {
+			t.Errorf("fwd %d bwd %d: got %s want %s", c.fwd, c.bwd, got, c.want)
+		}
+	}
+
+	// An unlimited walk stops at excluded nodes and terminates on
+	// cycles.
+	opts := Options{Roots: []string{"c"}, Exclude: []string{"a", "e"}, Mode: "fwd", FwdDepth: Unlimited}
+	s, err := Prune(graph, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := sliceNodes(graph, s), `"b" "c" "d"`; got != want {
+		t.Errorf("unlimited with excludes: got %s want %s", got, want)
+	}
+}
+
+func TestDistances(t *testing.T) {
+	graph, err := doparse(testgraph)
+	if err != nil {
+		t.Fatalf("parsing initial graph: %v", err)
+	}
+	s, err := Prune(graph, Options{Roots: []string{"c"}, Mode: "both", FwdDepth: Unlimited, BwdDepth: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := distNodes(graph, s.FwdDist), `"a"=2 "b"=1 "c"=0 "d"=1 "e"=2 "f"=3 "g"=3`; got != want {
+		t.Errorf("forward distances: got %s want %s", got, want)
+	}
+	if got, want := distNodes(graph, s.BwdDist), `"a"=2 "c"=0 "e"=2 "f"=1 "g"=1`; got != want {
+		t.Errorf("backward distances: got %s want %s", got, want)
+	}
+}
+
+// benchGraph returns a graph with n nodes and about 4n edges, full of
+// cycles, for benchmarking.
+func benchGraph(n int) *zgr.Graph {
+	g := zgr.NewGraph()
+	id := func(i int) string { return fmt.Sprintf("\"n%d\"", i%n) }
+	for i := 0; i < n; i++ {
+		g.MakeNode(id(i), nil)
+	}
+	for i := 0; i < n; i++ {
+		for _, j := range []int{i + 1, i*7 + 3, i*13 + 5, i / 2} {
+			// Duplicates are simply rejected.
+			g.AddEdge(id(i), id(j), nil)
+		}
+	}
+	return g
+}
+
+// recursiveWalk is the depth-first walk grprune used to do, without a
+// visited set, kept for comparison in the benchmarks.
+func recursiveWalk(g zgr.Topology, idx uint32, depth int, dcutoff int, inc map[uint32]bool) {
+	inc[idx] = true
+	if depth >= dcutoff {
+		return
+	}
+	for nb := range g.SuccessorIndices(idx) {
+		recursiveWalk(g, nb, depth+1, dcutoff, inc)
+	}
+}
+
+func TestBFSMatchesRecursive(t *testing.T) {
+	g := benchGraph(500)
+	for depth := 0; depth <= 5; depth++ {
+		inc := make(map[uint32]bool)
+		recursiveWalk(g, 0, 0, depth, inc)
+		dist := bfs(g.SuccessorIndices, 0, depth, nil)
+		if len(dist) != len(inc) {
+			t.Errorf("depth %d: bfs reached %d nodes, recursive walk %d", depth, len(dist), len(inc))
+		}
+		for idx := range inc {
+			if d, ok := dist[idx]; !ok || d > depth {
+				t.Errorf("depth %d: node %d missing or too far (%d)", depth, idx, d)
+			}
+		}
+	}
+}
+
+func BenchmarkPrune(b *testing.B) {
+	// On the small graph every node is reached within a few steps, so
+	// the recursive walk spends nearly all its time revisiting nodes.
+	for _, n := range []int{200, 100000} {
+		g := benchGraph(n)
+		for _, depth := range []int{4, 8, 10} {
+			b.Run(fmt.Sprintf("n=%d/bfs/depth=%d", n, depth), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					bfs(g.SuccessorIndices, 0, depth, nil)
+				}
+			})
+			b.Run(fmt.Sprintf("n=%d/recursive/depth=%d", n, depth), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					recursiveWalk(g, 0, 0, depth, make(map[uint32]bool))
+				}
+			})
+		}
+		b.Run(fmt.Sprintf("n=%d/bfs/unlimited", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				bfs(g.SuccessorIndices, 0, Unlimited, nil)
+			}
+		})
+	}
+}
+
+func TestPathSlice(t *testing.T) {
+	const ins = `digraph P {
+   "a"
+   "b"
+   "c"
+   "d"
+   "e"
+   "x"
+   "y"
+   "a" -> "b"
+   "b" -> "c"
+   "c" -> "e"
+   "a" -> "d"
+   "d" -> "e"
+   "b" -> "x"
+   "y" -> "e"
+   "e" -> "a"
+ }`
+	graph, err := doparse(ins)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		to      []string
+		exclude []string
+		maxlen  int
+		want    string
+	}{
+		{[]string{"e"}, nil, Unlimited, `"a" "b" "c" "d" "e"`},
+		{[]string{"e"}, nil, 2, `"a" "d" "e"`},
+		{[]string{"e"}, []string{"d"}, Unlimited, `"a" "b" "c" "e"`},
+		{[]string{"c", "x"}, nil, 2, `"a" "b" "c" "x"`},
+		{[]string{"y"}, nil, Unlimited, ``},
+		{[]string{"a"}, nil, 0, `"a"`},
+	}
+	for _, c := range cases {
+		opts := Options{Roots: []string{"a"}, To: c.to, Exclude: c.exclude, FwdDepth: c.maxlen}
+		s, err := Prune(graph, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := sliceNodes(graph, s); got != c.want {
+			t.Errorf("to %v excluding %v len %d: got %s want %s", c.to, c.exclude, c.maxlen, got, c.want)
+		}
+	}
+	s, err := Prune(graph, Options{Roots: []string{"a"}, To: []string{"e"}, FwdDepth: Unlimited})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := distNodes(graph, s.TargetDist), `"a"=2 "b"=2 "c"=1 "d"=1 "e"=0`; got != want {
+		t.Errorf("target distances: got %s want %s", got, want)
+	}
+	if got, want := distNodes(graph, s.FwdDist), `"a"=0 "b"=1 "c"=2 "d"=1 "e"=2`; got != want {
+		t.Errorf("path forward distances: got %s want %s", got, want)
+	}
+	if len(s.BwdDist) != 0 {
+		t.Errorf("path slice has backward distances %v", s.BwdDist)
+	}
+	if _, err := Prune(graph, Options{Roots: []string{"a"}, To: []string{"zz"}}); err == nil {
+		t.Errorf("expected error for unknown target")
+	}
+}
+
+func TestFrontier(t *testing.T) {
+	const ins = `digraph F {
+   node [style=filled]
+   "p"
+   "a"
+   "b"
+   "c"
+   "d"
+   "e"
+   "x"
+   "p" -> "a"
+   "a" -> "b"
+   "b" -> "c"
+   "b" -> "d"
+   "b" -> "x"
+   "c" -> "e"
+ }`
+	graph, err := doparse(ins)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := Options{Roots: []string{"a"}, Mode: "both", FwdDepth: 1, BwdDepth: 0, Exclude: []string{"x"}}
+	s, err := Prune(graph, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := fmt.Sprintf("%v %v", s.HiddenSuccs, s.HiddenPreds)
+	b, _ := graph.LookupIndex(`"b"`)
+	a, _ := graph.LookupIndex(`"a"`)
+	if want := fmt.Sprintf("map[%d:2] map[%d:1]", b, a); counts != want {
+		t.Errorf("hidden counts: got %s want %s", counts, want)
+	}
+
+	cases := []struct {
+		mode FrontierMode
+		want string
+	}{
+		{FrontierAnnotate, `digraph F {
+node [style=filled]
+"a"  [style="filled,dashed", xlabel="+1 in"]
+"b"  [style="filled,dashed", xlabel="+2 out"]
+"a" -> "b"
+}
+`},
+		{FrontierPlaceholders, `digraph F {
+node [style=filled]
+"a" 
+"b" 
+"a...preds"  [label="… 1 more", shape=plaintext, style=dashed]
+"b...succs"  [label="… 2 more", shape=plaintext, style=dashed]
+"a" -> "b"
+"a...preds" -> "a" [style=dashed]
+"b" -> "b...succs" [style=dashed]
+}
+`},
+	}
+	for _, c := range cases {
+		opts := base
+		opts.Frontier = c.mode
+		var sb strings.Builder
+		wopts := zgr.WriteOptions{Order: zgr.InputOrder, EmitDefaults: true}
+		if err := WritePruned(graph, opts, &sb, wopts); err != nil {
+			t.Fatal(err)
+		}
+		if got := sb.String(); got != c.want {
+			t.Errorf("frontier mode %d:\ngot:\n%s\nwant:\n%s", c.mode, got, c.want)
+		}
+	}
+
+	// Unlimited walks have no frontier.
+	opts := base
+	opts.FwdDepth, opts.BwdDepth = Unlimited, Unlimited
+	if s, err = Prune(graph, opts); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.HiddenSuccs)+len(s.HiddenPreds) != 0 {
+		t.Errorf("unexpected frontier %v %v", s.HiddenSuccs, s.HiddenPreds)
+	}
+}
+
+func TestBypass(t *testing.T) {
+	const ins = `digraph B {
+   "a"
+   "b"
+   "h"
+   "h2"
+   "c"
+   "d"
+   "e"
+   "a" -> "h"
+   "b" -> "h"
+   "h" -> "c"
+   "h" -> "h2"
+   "h2" -> "d"
+   "a" -> "c"
+   "d" -> "e"
+ }`
+	graph, err := doparse(ins)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := Options{Roots: []string{"a"}, Mode: "fwd", FwdDepth: 3, Exclude: []string{"h", "h2"}}
+
+	// Without Bypass the walk stops at the hub.
+	var sb strings.Builder
+	if err := WritePruned(graph, opts, &sb, zgr.WriteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	want := `digraph B {
+"a" 
+"c" 
+"a" -> "c"
+}
+`
+	if got := sb.String(); got != want {
+		t.Errorf("no bypass:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+
+	// With it, d is reached through both hubs and e is at depth 4,
+	// beyond the limit. a -> c already exists, so gets no bypass edge.
+	opts.Bypass = true
+	sb.Reset()
+	if err := WritePruned(graph, opts, &sb, zgr.WriteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	want = `digraph B {
+"a" 
+"c" 
+"d" 
+"a" -> "c"
+"a" -> "d" [style=dashed]
+}
+`
+	if got := sb.String(); got != want {
+		t.Errorf("bypass:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+
+	// Backward from c: b reaches c only through h.
+	opts = Options{Roots: []string{"c"}, Mode: "bwd", BwdDepth: Unlimited, Exclude: []string{"h"}, Bypass: true}
+	s, err := Prune(graph, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range s.Bypassed {
+		got = append(got, graph.NodeID(e[0])+"->"+graph.NodeID(e[1]))
+	}
+	if g, w := strings.Join(got, " "), `"b"->"c"`; g != w {
+		t.Errorf("bwd bypass edges: got %s want %s", g, w)
+	}
+}
+
+func TestEdgeFilter(t *testing.T) {
+	const ins = `digraph E {
+   edge [kind=call]
+   "a"
+   "b"
+   "c"
+   "d"
+   "p"
+   "q"
+   "a" -> "b"
+   "b" -> "c" [kind=ref]
+   "a" -> "d" [style=dashed]
+   "p" -> "a"
+   "q" -> "a" [kind="ref"]
+ }`
+	graph, err := doparse(ins)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		mode     string
+		fwd, bwd string
+		to       []string
+		want     string
+	}{
+		{"both", "", "", nil, `"a" "b" "c" "d" "p" "q"`},
+		{"fwd", "kind=call", "", nil, `"a" "b" "d"`},
+		{"fwd", "style!=dashed", "", nil, `"a" "b" "c"`},
+		{"fwd", "kind=call,style!=dashed", "", nil, `"a" "b"`},
+		{"fwd", "kind~^r", "", nil, `"a"`},
+		{"both", "kind!~ref", "kind=ref", nil, `"a" "b" "d" "q"`},
+		{"", "kind=call", "", []string{"c"}, ``},
+		{"", "kind!=xyz", "", []string{"c"}, `"a" "b" "c"`},
+	}
+	for _, c := range cases {
+		opts := Options{Roots: []string{"a"}, Mode: c.mode, FwdDepth: Unlimited, BwdDepth: Unlimited,
+			FwdEdges: c.fwd, BwdEdges: c.bwd, To: c.to}
+		s, err := Prune(graph, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := sliceNodes(graph, s); got != c.want {
+			t.Errorf("mode %s fwd %q bwd %q to %v: got %s want %s", c.mode, c.fwd, c.bwd, c.to, got, c.want)
+		}
+	}
+
+	// The same on a frozen graph.
+	s, err := Prune(graph.Freeze(), Options{Roots: []string{"a"}, Mode: "bwd", BwdDepth: 1, BwdEdges: "kind=call"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := sliceNodes(graph, s), `"a" "p"`; got != want {
+		t.Errorf("frozen: got %s want %s", got, want)
+	}
+
+	for _, bad := range []string{"kind", "=call", "kind~(", "kind!call"} {
+		if _, err := Prune(graph, Options{Roots: []string{"a"}, Mode: "fwd", FwdEdges: bad}); err == nil {
+			t.Errorf("expected error for edge filter %q", bad)
+		}
+	}
+}
+
+func TestBypassEdgeFilter(t *testing.T) {
+	// b is reached from a through the hub h by call edges only; c only
+	// through a ref edge out of h.
+	const ins = `digraph BF {
+   edge [kind=call]
+   "a"
+   "h"
+   "b"
+   "c"
+   "a" -> "h"
+   "h" -> "b"
+   "h" -> "c" [kind=ref]
+   "c" -> "a"
+ }`
+	graph, err := doparse(ins)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bypassed := func(s *Slice) string {
+		var res []string
+		for _, e := range s.Bypassed {
+			res = append(res, graph.NodeID(e[0])+"->"+graph.NodeID(e[1]))
+		}
+		return strings.Join(res, " ")
+	}
+	cases := []struct {
+		root, mode string
+		fwd, bwd   string
+		want       string
+	}{
+		{"a", "fwd", "", "", `"a"->"b" "a"->"c"`},
+		{"a", "fwd", "kind=call", "", `"a"->"b"`},
+		{"a", "fwd", "kind=call", "kind=ref", `"a"->"b"`},
+		{"b", "bwd", "kind=ref", "kind=call", `"a"->"b"`},
+		{"b", "bwd", "kind=call", "kind=ref", ``},
+		// a -> h -> c takes a call edge and then a ref edge, so
+		// neither walk from c could follow it.
+		{"c", "both", "kind=call", "kind=ref", `"a"->"b"`},
+	}
+	for _, c := range cases {
+		opts := Options{Roots: []string{c.root}, Mode: c.mode, FwdDepth: Unlimited, BwdDepth: Unlimited,
+			Exclude: []string{"h"}, Bypass: true, FwdEdges: c.fwd, BwdEdges: c.bwd}
+		s, err := Prune(graph, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := bypassed(s); got != c.want {
+			t.Errorf("root %s mode %s fwd %q bwd %q: got %s want %s", c.root, c.mode, c.fwd, c.bwd, got, c.want)
+		}
+	}
+}
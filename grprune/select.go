@@ -0,0 +1,140 @@
+package grprune
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/thanm/grvutils/zgr"
+)
+
+// Roots and excludes are given as node selectors. A plain string is an
+// exact node ID, as before; the prefixed forms below pick nodes by
+// label or attribute instead:
+//
+//	id:ID          exact node ID (for IDs that contain a ':')
+//	label:TEXT     exact label
+//	idre:RE        regular expression on the node ID
+//	labelre:RE     regular expression on the label
+//	attr:KEY=VAL   exact value of attribute KEY
+//
+// IDs, labels and values are matched without their DOT quotes, and the
+// regular expressions are unanchored. A selector normally has to match
+// exactly one node; see Options.AllMatches.
+
+type selector struct {
+	text string
+	kind string // "id", "label", "idre", "labelre" or "attr"
+	key  string
+	val  string
+	re   *regexp.Regexp
+}
+
+func parseSelector(s string) (*selector, error) {
+	sel := &selector{text: s, kind: "id", val: s}
+	kind, rest, found := strings.Cut(s, ":")
+	if !found {
+		return sel, nil
+	}
+	switch kind {
+	case "id", "label":
+		sel.kind, sel.val = kind, rest
+	case "idre", "labelre":
+		re, err := regexp.Compile(rest)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("error: bad pattern in selector '%s': %v", s, err))
+		}
+		sel.kind, sel.re = kind, re
+	case "attr":
+		key, val, ok := strings.Cut(rest, "=")
+		if !ok || key == "" {
+			return nil, errors.New(fmt.Sprintf("error: selector '%s' should have the form attr:KEY=VALUE", s))
+		}
+		sel.kind, sel.key, sel.val = kind, key, val
+	}
+	// Anything else is an ordinary ID that happens to contain a ':'.
+	return sel, nil
+}
+
+func label(g zgr.Topology, idx uint32) string {
+	l, _ := g.NodeAttr(idx, "label")
+	return zgr.Unquote(l)
+}
+
+func (sel *selector) match(g zgr.Topology, idx uint32) bool {
+	switch sel.kind {
+	case "id":
+		return zgr.Unquote(g.NodeID(idx)) == sel.val
+	case "label":
+		return label(g, idx) == sel.val
+	case "idre":
+		return sel.re.MatchString(zgr.Unquote(g.NodeID(idx)))
+	case "labelre":
+		return sel.re.MatchString(label(g, idx))
+	}
+	v, ok := g.NodeAttr(idx, sel.key)
+	return ok && zgr.Unquote(v) == sel.val
+}
+
+// maxCandidates limits how many nodes are listed in an error message.
+const maxCandidates = 10
+
+func describe(g zgr.Topology, nodes []uint32) string {
+	var sb strings.Builder
+	for i, idx := range nodes {
+		if i == maxCandidates {
+			sb.WriteString(fmt.Sprintf("\n  ... and %d more", len(nodes)-i))
+			break
+		}
+		sb.WriteString("\n  " + zgr.Unquote(g.NodeID(idx)))
+		if l := label(g, idx); l != "" {
+			sb.WriteString(fmt.Sprintf(" (label %q)", l))
+		}
+	}
+	return sb.String()
+}
+
+// selectNodes returns the nodes matched by the selector s. what names
+// the role of the nodes ("root" or "exclude") for error messages.
+func selectNodes(g zgr.Topology, s string, what string, all bool) ([]uint32, error) {
+	sel, err := parseSelector(s)
+	if err != nil {
+		return nil, err
+	}
+	if sel.kind == "id" {
+		if idx, ok := g.LookupIndex(fmt.Sprintf("\"%s\"", sel.val)); ok {
+			return []uint32{idx}, nil
+		}
+	}
+	var res []uint32
+	for idx := uint32(0); idx < g.GetNodeCount(); idx++ {
+		if sel.match(g, idx) {
+			res = append(res, idx)
+		}
+	}
+	switch {
+	case len(res) == 0 && sel.kind == "id":
+		// Opaque IDs are easily confused with labels, so suggest any
+		// nodes with a matching label.
+		id := sel.val
+		msg := fmt.Sprintf("error: unable to locate %s node '%s'", what, id)
+		lsel := &selector{kind: "label", val: id}
+		var cands []uint32
+		for idx := uint32(0); idx < g.GetNodeCount(); idx++ {
+			if lsel.match(g, idx) {
+				cands = append(cands, idx)
+			}
+		}
+		if len(cands) != 0 {
+			msg += fmt.Sprintf("; nodes labeled '%s' (use label:%s):%s", id, id, describe(g, cands))
+		}
+		return nil, errors.New(msg)
+	case len(res) == 0:
+		return nil, errors.New(fmt.Sprintf("error: no node matches %s selector '%s'", what, s))
+	case len(res) > 1 && !all:
+		return nil, errors.New(fmt.Sprintf("error: %s selector '%s' is ambiguous, matching %d nodes:%s",
+			what, s, len(res), describe(g, res)))
+	}
+	return res, nil
+}
@@ -0,0 +1,387 @@
+// Package grquery implements a small language for selecting nodes of a
+// zgr.Graph, for use where exact node IDs are awkward to spell out.
+//
+// A query is a boolean expression over predicates on a single node:
+//
+//	expr  := expr "||" expr | expr "&&" expr | "!" expr | "(" expr ")" | pred
+//	pred  := field | field op value
+//	op    := "==" | "!=" | "~" | "!~" | "<" | "<=" | ">" | ">="
+//	field := name | "@" name
+//	value := name | number | quoted-string
+//
+// The fields id, indeg, outdeg and deg are the node's ID and degree
+// counts; any other name refers to the node attribute of that name, and
+// "@name" always refers to an attribute (so "@id" is the DOT id
+// attribute rather than the node ID). A field on its own tests that the
+// attribute is present. "~" and "!~" match a regular expression (see
+// package regexp; the match is unanchored), "==" and "!=" compare
+// strings, and the ordering operators compare numerically and are false
+// when either side is not a number. IDs and attribute values are
+// compared without their DOT quotes, so
+//
+//	shape==box && fontcolor~"red"
+//
+// matches nodes whose shape is box or "box" and whose fontcolor
+// contains red. "&&" binds more tightly than "||".
+package grquery
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/thanm/grvutils/zgr"
+)
+
+// Tokens
+const (
+	tEOF = iota
+	tName
+	tString
+	tAt
+	tOp
+	tAnd
+	tOr
+	tNot
+	tLParen
+	tRParen
+)
+
+type token struct {
+	kind int
+	str  string
+	pos  int
+}
+
+func lex(s string) ([]token, error) {
+	var toks []token
+	i := 0
+	for i < len(s) {
+		c := s[i]
+		start := i
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+			continue
+		case c == '(':
+			toks = append(toks, token{tLParen, "(", start})
+			i++
+		case c == ')':
+			toks = append(toks, token{tRParen, ")", start})
+			i++
+		case c == '@':
+			toks = append(toks, token{tAt, "@", start})
+			i++
+		case strings.HasPrefix(s[i:], "&&"):
+			toks = append(toks, token{tAnd, "&&", start})
+			i += 2
+		case strings.HasPrefix(s[i:], "||"):
+			toks = append(toks, token{tOr, "||", start})
+			i += 2
+		case strings.HasPrefix(s[i:], "=="), strings.HasPrefix(s[i:], "!="),
+			strings.HasPrefix(s[i:], "!~"), strings.HasPrefix(s[i:], "<="),
+			strings.HasPrefix(s[i:], ">="):
+			toks = append(toks, token{tOp, s[i : i+2], start})
+			i += 2
+		case c == '~' || c == '<' || c == '>':
+			toks = append(toks, token{tOp, s[i : i+1], start})
+			i++
+		case c == '!':
+			toks = append(toks, token{tNot, "!", start})
+			i++
+		case c == '"':
+			var sb strings.Builder
+			i++
+			for i < len(s) && s[i] != '"' {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				sb.WriteByte(s[i])
+				i++
+			}
+			if i >= len(s) {
+				return nil, mkerror(start, "unterminated string")
+			}
+			i++
+			toks = append(toks, token{tString, sb.String(), start})
+		case isNameChar(c):
+			for i < len(s) && isNameChar(s[i]) {
+				i++
+			}
+			toks = append(toks, token{tName, s[start:i], start})
+		default:
+			return nil, mkerror(start, fmt.Sprintf("unexpected character %q", c))
+		}
+	}
+	return append(toks, token{tEOF, "", len(s)}), nil
+}
+
+func isNameChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' ||
+		c >= '0' && c <= '9' || c == '_' || c == '.' || c == '-'
+}
+
+func mkerror(pos int, msg string) error {
+	return errors.New(fmt.Sprintf("query: at offset %d: %s", pos, msg))
+}
+
+// Expression tree
+type expr interface {
+	eval(g *zgr.Graph, n *zgr.Node) bool
+	String() string
+}
+
+type andExpr struct{ l, r expr }
+type orExpr struct{ l, r expr }
+type notExpr struct{ x expr }
+
+type field struct {
+	name   string
+	isAttr bool
+}
+
+type predExpr struct {
+	f     field
+	op    string // "" for a presence test
+	val   string
+	num   float64
+	isnum bool
+	re    *regexp.Regexp
+}
+
+func (e *andExpr) eval(g *zgr.Graph, n *zgr.Node) bool {
+	return e.l.eval(g, n) && e.r.eval(g, n)
+}
+
+func (e *orExpr) eval(g *zgr.Graph, n *zgr.Node) bool {
+	return e.l.eval(g, n) || e.r.eval(g, n)
+}
+
+func (e *notExpr) eval(g *zgr.Graph, n *zgr.Node) bool {
+	return !e.x.eval(g, n)
+}
+
+func (e *andExpr) String() string { return "(" + e.l.String() + " && " + e.r.String() + ")" }
+func (e *orExpr) String() string  { return "(" + e.l.String() + " || " + e.r.String() + ")" }
+func (e *notExpr) String() string { return "!" + e.x.String() }
+
+var reserved = map[string]bool{"id": true, "indeg": true, "outdeg": true, "deg": true}
+
+func (f field) String() string {
+	if f.isAttr && reserved[f.name] {
+		return "@" + f.name
+	}
+	return f.name
+}
+
+func (e *predExpr) String() string {
+	if e.op == "" {
+		return e.f.String()
+	}
+	return e.f.String() + e.op + strconv.Quote(e.val)
+}
+
+// lookup returns the value of f for node n, and whether it is present.
+func (f field) lookup(g *zgr.Graph, n *zgr.Node) (string, bool) {
+	if !f.isAttr {
+		switch f.name {
+		case "id":
+			return zgr.Unquote(n.Id()), true
+		case "indeg":
+			return strconv.Itoa(len(g.GetInEdges(n))), true
+		case "outdeg":
+			return strconv.Itoa(len(g.GetEdges(n))), true
+		case "deg":
+			return strconv.Itoa(len(g.GetInEdges(n)) + len(g.GetEdges(n))), true
+		}
+	}
+	v, ok := g.GetNodeAttrs(n)[f.name]
+	if !ok {
+		v, ok = g.GetNodeDefaults()[f.name]
+	}
+	return zgr.Unquote(v), ok
+}
+
+func (e *predExpr) eval(g *zgr.Graph, n *zgr.Node) bool {
+	v, ok := e.f.lookup(g, n)
+	switch e.op {
+	case "":
+		return ok
+	case "==":
+		return v == e.val
+	case "!=":
+		return v != e.val
+	case "~":
+		return e.re.MatchString(v)
+	case "!~":
+		return !e.re.MatchString(v)
+	}
+	x, err := strconv.ParseFloat(v, 64)
+	if err != nil || !e.isnum {
+		return false
+	}
+	switch e.op {
+	case "<":
+		return x < e.num
+	case "<=":
+		return x <= e.num
+	case ">":
+		return x > e.num
+	}
+	return x >= e.num
+}
+
+type parser struct {
+	toks []token
+	pos  int
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) parseOr() (expr, error) {
+	l, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tOr {
+		p.next()
+		r, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l = &orExpr{l, r}
+	}
+	return l, nil
+}
+
+func (p *parser) parseAnd() (expr, error) {
+	l, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tAnd {
+		p.next()
+		r, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l = &andExpr{l, r}
+	}
+	return l, nil
+}
+
+func (p *parser) parseUnary() (expr, error) {
+	t := p.next()
+	switch t.kind {
+	case tNot:
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notExpr{x}, nil
+	case tLParen:
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if rp := p.next(); rp.kind != tRParen {
+			return nil, mkerror(rp.pos, "expected ')'")
+		}
+		return x, nil
+	case tAt:
+		nt := p.next()
+		if nt.kind != tName && nt.kind != tString {
+			return nil, mkerror(nt.pos, "expected attribute name after '@'")
+		}
+		return p.parsePred(field{name: nt.str, isAttr: true})
+	case tName, tString:
+		return p.parsePred(field{name: t.str, isAttr: t.kind == tString || !reserved[t.str]})
+	case tEOF:
+		return nil, mkerror(t.pos, "unexpected end of query")
+	}
+	return nil, mkerror(t.pos, fmt.Sprintf("unexpected %q", t.str))
+}
+
+func (p *parser) parsePred(f field) (expr, error) {
+	pe := &predExpr{f: f}
+	if p.peek().kind != tOp {
+		return pe, nil
+	}
+	pe.op = p.next().str
+	vt := p.next()
+	if vt.kind != tName && vt.kind != tString {
+		return nil, mkerror(vt.pos, fmt.Sprintf("expected value after '%s'", pe.op))
+	}
+	pe.val = vt.str
+	switch pe.op {
+	case "~", "!~":
+		re, err := regexp.Compile(pe.val)
+		if err != nil {
+			return nil, mkerror(vt.pos, err.Error())
+		}
+		pe.re = re
+	case "<", "<=", ">", ">=":
+		x, err := strconv.ParseFloat(pe.val, 64)
+		if err != nil {
+			return nil, mkerror(vt.pos, fmt.Sprintf("'%s' needs a number, got %q", pe.op, pe.val))
+		}
+		pe.num, pe.isnum = x, true
+	}
+	return pe, nil
+}
+
+// Query is a compiled node selection query.
+type Query struct {
+	e expr
+}
+
+// Parse compiles the query in s.
+func Parse(s string) (*Query, error) {
+	toks, err := lex(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tEOF {
+		return nil, mkerror(t.pos, fmt.Sprintf("unexpected %q", t.str))
+	}
+	return &Query{e: e}, nil
+}
+
+// String returns the query in fully parenthesized form.
+func (q *Query) String() string {
+	return q.e.String()
+}
+
+// Match reports whether node n of g satisfies the query.
+func (q *Query) Match(g *zgr.Graph, n *zgr.Node) bool {
+	return q.e.eval(g, n)
+}
+
+// Select returns the indices of the nodes of g that satisfy the query,
+// in index order.
+func (q *Query) Select(g *zgr.Graph) []uint32 {
+	var res []uint32
+	for n := range g.Nodes() {
+		if q.Match(g, n) {
+			res = append(res, n.Idx())
+		}
+	}
+	return res
+}
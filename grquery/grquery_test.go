@@ -0,0 +1,90 @@
+package grquery
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/thanm/grvutils/grparser"
+	"github.com/thanm/grvutils/zgr"
+)
+
+const testgraph = `digraph G {
+   node [shape=ellipse]
+   "_ZN3foo3barEv" [label="foo::bar()", shape=box, fontcolor="darkred"]
+   "_ZN3foo3bazEv" [label="foo::baz()", weight=3]
+   "main" [shape=box, id="entry"]
+   "x\"y" [weight=10]
+   "main" -> "_ZN3foo3barEv"
+   "main" -> "_ZN3foo3bazEv"
+   "_ZN3foo3barEv" -> "_ZN3foo3bazEv"
+   "x\"y" -> "main"
+ }`
+
+func TestSelect(t *testing.T) {
+	g := zgr.NewGraph()
+	if err := grparser.ParseGraph(strings.NewReader(testgraph), g); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		query string
+		want  string
+	}{
+		{`shape==box && fontcolor~"red"`, "[0]"},
+		{`shape==box`, "[0 2]"},
+		{`shape==ellipse`, "[1 3]"},
+		{`id~"^_ZN3foo"`, "[0 1]"},
+		{`label~"baz"`, "[1]"},
+		{`label`, "[0 1]"},
+		{`!label && shape!=box`, "[3]"},
+		{`indeg==0`, "[3]"},
+		{`outdeg>=2 || indeg>1`, "[1 2]"},
+		{`deg==3`, "[2]"},
+		{`weight>5`, "[3]"},
+		{`weight<5`, "[1]"},
+		{`@id==entry`, "[2]"},
+		{`id=="x\"y"`, "[3]"},
+		{`(shape==box || weight) && !(id==main)`, "[0 1 3]"},
+		{`id!~foo`, "[2 3]"},
+	}
+	for _, c := range cases {
+		q, err := Parse(c.query)
+		if err != nil {
+			t.Errorf("Parse(%s): %v", c.query, err)
+			continue
+		}
+		if got := fmt.Sprint(q.Select(g)); got != c.want {
+			t.Errorf("%s (%s): got %s want %s", c.query, q, got, c.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	bad := []string{
+		``,
+		`shape==`,
+		`shape==box &&`,
+		`(shape==box`,
+		`shape==box)`,
+		`id~"("`,
+		`weight>heavy`,
+		`label=="open`,
+		`shape = box`,
+		`@`,
+	}
+	for _, s := range bad {
+		if _, err := Parse(s); err == nil {
+			t.Errorf("Parse(%s): expected error", s)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	q, err := Parse(`a || b && !c~x`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := q.String(), `(a || (b && !c~"x"))`; got != want {
+		t.Errorf("got %s want %s", got, want)
+	}
+}
@@ -0,0 +1,207 @@
+package zgr
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Binary encoding of a Graph, used by MarshalBinary/UnmarshalBinary.
+// All integers are unsigned varints and strings are length-prefixed:
+//
+//	magic "ZGRB", version
+//	flags (bit 0 strict, bit 1 undirected), name
+//	attribute table: count, then key and value of each entry
+//	graph attrs, node defaults, edge defaults: count, then table indices
+//	nodes: count, then for each node its ID and attribute indices
+//	edges: count, then for each edge src, sink and attribute indices
+//
+// Adjacency lists and lookup tables are rebuilt on decode. The version
+// is bumped whenever the layout changes; older versions are rejected.
+
+const (
+	binMagic   = "ZGRB"
+	binVersion = 1
+)
+
+type binWriter struct {
+	buf []byte
+}
+
+func (bw *binWriter) uint(v uint64) {
+	bw.buf = binary.AppendUvarint(bw.buf, v)
+}
+
+func (bw *binWriter) str(s string) {
+	bw.uint(uint64(len(s)))
+	bw.buf = append(bw.buf, s...)
+}
+
+func (bw *binWriter) list(l []uint32) {
+	bw.uint(uint64(len(l)))
+	for _, v := range l {
+		bw.uint(uint64(v))
+	}
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (g *Graph) MarshalBinary() ([]byte, error) {
+	bw := &binWriter{buf: []byte(binMagic)}
+	bw.uint(binVersion)
+	flags := uint64(0)
+	if g.strict {
+		flags |= 1
+	}
+	if g.undirected {
+		flags |= 2
+	}
+	bw.uint(flags)
+	bw.str(g.name)
+	bw.uint(uint64(len(g.allattrs)))
+	for _, a := range g.allattrs {
+		bw.str(a.key)
+		bw.str(a.val)
+	}
+	bw.list(g.attrs)
+	bw.list(g.nattrs)
+	bw.list(g.eattrs)
+	bw.uint(uint64(len(g.nodes)))
+	for i := range g.nodes {
+		bw.str(g.nodes[i].id)
+		bw.list(g.nodes[i].attrs)
+	}
+	bw.uint(uint64(len(g.edges)))
+	for i := range g.edges {
+		bw.uint(uint64(g.edges[i].src))
+		bw.uint(uint64(g.edges[i].sink))
+		bw.list(g.edges[i].attrs)
+	}
+	return bw.buf, nil
+}
+
+var errTruncated = errors.New("UnmarshalBinary: truncated data")
+
+type binReader struct {
+	buf []byte
+	err error
+}
+
+func (br *binReader) uint() uint64 {
+	if br.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(br.buf)
+	if n <= 0 {
+		br.err = errTruncated
+		return 0
+	}
+	br.buf = br.buf[n:]
+	return v
+}
+
+// count reads a length and checks it against what remains of the
+// input, so corrupt data can't trigger a huge allocation.
+func (br *binReader) count() int {
+	v := br.uint()
+	if v > uint64(len(br.buf)) {
+		br.err = errTruncated
+		return 0
+	}
+	return int(v)
+}
+
+func (br *binReader) str() string {
+	n := br.count()
+	if br.err != nil {
+		return ""
+	}
+	s := string(br.buf[:n])
+	br.buf = br.buf[n:]
+	return s
+}
+
+// idx reads an index that must be less than limit.
+func (br *binReader) idx(limit int, what string) uint32 {
+	v := br.uint()
+	if br.err == nil && v >= uint64(limit) {
+		br.err = errors.New(fmt.Sprintf("UnmarshalBinary: %s index %d out of range", what, v))
+	}
+	return uint32(v)
+}
+
+func (br *binReader) list(limit int) []uint32 {
+	n := br.count()
+	res := make([]uint32, 0, n)
+	for i := 0; i < n && br.err == nil; i++ {
+		res = append(res, br.idx(limit, "attribute"))
+	}
+	return res
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler. It replaces
+// the contents of g with the graph encoded in data.
+func (g *Graph) UnmarshalBinary(data []byte) error {
+	if len(data) < len(binMagic) || string(data[:len(binMagic)]) != binMagic {
+		return errors.New("UnmarshalBinary: not an encoded graph")
+	}
+	br := &binReader{buf: data[len(binMagic):]}
+	if v := br.uint(); br.err == nil && v != binVersion {
+		return errors.New(fmt.Sprintf("UnmarshalBinary: unsupported version %d", v))
+	}
+	ng := NewGraph()
+	flags := br.uint()
+	ng.strict = flags&1 != 0
+	ng.undirected = flags&2 != 0
+	ng.name = br.str()
+	na := br.count()
+	for i := 0; i < na && br.err == nil; i++ {
+		a := Attr{key: br.str(), val: br.str()}
+		if _, ok := ng.attrtab[a]; !ok {
+			ng.attrtab[a] = uint32(len(ng.allattrs))
+		}
+		ng.allattrs = append(ng.allattrs, a)
+	}
+	ng.attrs = br.list(na)
+	ng.nattrs = br.list(na)
+	ng.eattrs = br.list(na)
+	nn := br.count()
+	for i := 0; i < nn && br.err == nil; i++ {
+		id := br.str()
+		attrs := br.list(na)
+		if br.err != nil {
+			break
+		}
+		if _, ok := ng.ntab[id]; ok {
+			return errors.New(fmt.Sprintf("UnmarshalBinary: collision on node id %s", id))
+		}
+		n := Node{id: id, idx: uint32(i), attrs: attrs}
+		n.label = ng.attrMap(attrs)["label"]
+		ng.ntab[id] = uint32(i)
+		ng.nodes = append(ng.nodes, n)
+	}
+	ne := br.count()
+	for i := 0; i < ne && br.err == nil; i++ {
+		src := br.idx(len(ng.nodes), "node")
+		sink := br.idx(len(ng.nodes), "node")
+		attrs := br.list(na)
+		if br.err != nil {
+			break
+		}
+		cand := npair{src: src, sink: sink}
+		if _, ok := ng.etab[cand]; ok {
+			return errors.New(fmt.Sprintf("UnmarshalBinary: duplicate edge %d -> %d", src, sink))
+		}
+		ng.etab[cand] = i
+		ng.edges = append(ng.edges, Edge{src: src, sink: sink, attrs: attrs})
+		ng.nodes[src].outadjlist = append(ng.nodes[src].outadjlist, uint32(i))
+		ng.nodes[sink].inadjlist = append(ng.nodes[sink].inadjlist, uint32(i))
+	}
+	if br.err != nil {
+		return br.err
+	}
+	if len(br.buf) != 0 {
+		return errors.New("UnmarshalBinary: trailing data")
+	}
+	*g = *ng
+	return nil
+}
@@ -0,0 +1,227 @@
+package zgr
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// AttrChange describes a single attribute that differs between two
+// versions of a node, edge or graph. OldPresent is false if the
+// attribute was added, NewPresent is false if it was removed; the
+// values themselves may be empty either way.
+type AttrChange struct {
+	Key, Old, New          string
+	OldPresent, NewPresent bool
+}
+
+// EdgeKey identifies an edge by the IDs of its endpoints.
+type EdgeKey struct {
+	Src, Sink string
+}
+
+type NodeChange struct {
+	Id    string
+	Attrs []AttrChange
+}
+
+type EdgeChange struct {
+	Edge  EdgeKey
+	Attrs []AttrChange
+}
+
+// GraphDiff is the structural difference between two graphs, with
+// nodes matched by ID and edges by the IDs of their endpoints. All
+// lists are sorted by ID.
+type GraphDiff struct {
+	old, new *Graph
+
+	GraphAttrs   []AttrChange
+	AddedNodes   []string
+	RemovedNodes []string
+	ChangedNodes []NodeChange
+	AddedEdges   []EdgeKey
+	RemovedEdges []EdgeKey
+	ChangedEdges []EdgeChange
+}
+
+func diffAttrs(om, nm map[string]string) []AttrChange {
+	var res []AttrChange
+	for k, ov := range om {
+		if nv, ok := nm[k]; !ok || nv != ov {
+			res = append(res, AttrChange{Key: k, Old: ov, New: nv, OldPresent: true, NewPresent: ok})
+		}
+	}
+	for k, nv := range nm {
+		if _, ok := om[k]; !ok {
+			res = append(res, AttrChange{Key: k, New: nv, NewPresent: true})
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Key < res[j].Key })
+	return res
+}
+
+func (g *Graph) edgeKey(e *Edge) EdgeKey {
+	return EdgeKey{Src: g.nodes[e.src].id, Sink: g.nodes[e.sink].id}
+}
+
+func (g *Graph) lookupEdge(k EdgeKey) *Edge {
+	src, ok1 := g.ntab[k.Src]
+	sink, ok2 := g.ntab[k.Sink]
+	if !ok1 || !ok2 {
+		return nil
+	}
+	if eidx, ok := g.etab[npair{src: src, sink: sink}]; ok {
+		return &g.edges[eidx]
+	}
+	return nil
+}
+
+func lessEdgeKey(a, b EdgeKey) bool {
+	if a.Src != b.Src {
+		return a.Src < b.Src
+	}
+	return a.Sink < b.Sink
+}
+
+// Diff compares g (the old version) against ng (the new version).
+// Only the attributes set on the graph, nodes and edges themselves are
+// compared: node and edge defaults, the graph's name and whether it is
+// strict are ignored.
+func (g *Graph) Diff(ng *Graph) *GraphDiff {
+	d := &GraphDiff{old: g, new: ng}
+	d.GraphAttrs = diffAttrs(g.attrMap(g.attrs), ng.attrMap(ng.attrs))
+
+	for i := range g.nodes {
+		n := &g.nodes[i]
+		nn := ng.LookupNode(n.id)
+		if nn == nil {
+			d.RemovedNodes = append(d.RemovedNodes, n.id)
+			continue
+		}
+		if ac := diffAttrs(g.attrMap(n.attrs), ng.attrMap(nn.attrs)); ac != nil {
+			d.ChangedNodes = append(d.ChangedNodes, NodeChange{Id: n.id, Attrs: ac})
+		}
+	}
+	for i := range ng.nodes {
+		if g.LookupNode(ng.nodes[i].id) == nil {
+			d.AddedNodes = append(d.AddedNodes, ng.nodes[i].id)
+		}
+	}
+
+	for i := range g.edges {
+		k := g.edgeKey(&g.edges[i])
+		ne := ng.lookupEdge(k)
+		if ne == nil {
+			d.RemovedEdges = append(d.RemovedEdges, k)
+			continue
+		}
+		if ac := diffAttrs(g.attrMap(g.edges[i].attrs), ng.attrMap(ne.attrs)); ac != nil {
+			d.ChangedEdges = append(d.ChangedEdges, EdgeChange{Edge: k, Attrs: ac})
+		}
+	}
+	for i := range ng.edges {
+		k := ng.edgeKey(&ng.edges[i])
+		if g.lookupEdge(k) == nil {
+			d.AddedEdges = append(d.AddedEdges, k)
+		}
+	}
+
+	sort.Strings(d.AddedNodes)
+	sort.Strings(d.RemovedNodes)
+	sort.Slice(d.ChangedNodes, func(i, j int) bool {
+		return d.ChangedNodes[i].Id < d.ChangedNodes[j].Id
+	})
+	for _, l := range [][]EdgeKey{d.AddedEdges, d.RemovedEdges} {
+		sort.Slice(l, func(i, j int) bool { return lessEdgeKey(l[i], l[j]) })
+	}
+	sort.Slice(d.ChangedEdges, func(i, j int) bool {
+		return lessEdgeKey(d.ChangedEdges[i].Edge, d.ChangedEdges[j].Edge)
+	})
+	return d
+}
+
+// Empty reports whether the two graphs are structurally identical.
+func (d *GraphDiff) Empty() bool {
+	return len(d.GraphAttrs) == 0 &&
+		len(d.AddedNodes) == 0 && len(d.RemovedNodes) == 0 &&
+		len(d.ChangedNodes) == 0 && len(d.AddedEdges) == 0 &&
+		len(d.RemovedEdges) == 0 && len(d.ChangedEdges) == 0
+}
+
+func attrChangesString(acs []AttrChange) string {
+	var parts []string
+	for _, ac := range acs {
+		switch {
+		case !ac.OldPresent:
+			parts = append(parts, fmt.Sprintf("+%s=%s", ac.Key, ac.New))
+		case !ac.NewPresent:
+			parts = append(parts, fmt.Sprintf("-%s=%s", ac.Key, ac.Old))
+		default:
+			parts = append(parts, fmt.Sprintf("%s: %s -> %s", ac.Key, ac.Old, ac.New))
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+func (k EdgeKey) String() string {
+	return fmt.Sprintf("%s -> %s", k.Src, k.Sink)
+}
+
+// Write writes a textual report of the differences to w, one line per
+// change: '+' for additions, '-' for removals and '~' for attribute
+// changes.
+func (d *GraphDiff) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	if len(d.GraphAttrs) != 0 {
+		fmt.Fprintf(bw, "~ graph: %s\n", attrChangesString(d.GraphAttrs))
+	}
+	for _, id := range d.RemovedNodes {
+		fmt.Fprintf(bw, "- node %s\n", id)
+	}
+	for _, id := range d.AddedNodes {
+		fmt.Fprintf(bw, "+ node %s\n", id)
+	}
+	for _, nc := range d.ChangedNodes {
+		fmt.Fprintf(bw, "~ node %s: %s\n", nc.Id, attrChangesString(nc.Attrs))
+	}
+	for _, k := range d.RemovedEdges {
+		fmt.Fprintf(bw, "- edge %s\n", k)
+	}
+	for _, k := range d.AddedEdges {
+		fmt.Fprintf(bw, "+ edge %s\n", k)
+	}
+	for _, ec := range d.ChangedEdges {
+		fmt.Fprintf(bw, "~ edge %s: %s\n", ec.Edge, attrChangesString(ec.Attrs))
+	}
+	return bw.Flush()
+}
+
+// Overlay returns a graph combining both versions: everything in the
+// new graph, plus the nodes and edges that were removed from the old
+// one. Added elements are colored green and removed ones red.
+func (d *GraphDiff) Overlay() *Graph {
+	og, ng := d.old, d.new
+	m := ng.Filter(nil, nil)
+	for _, id := range d.AddedNodes {
+		m.SetNodeAttr(m.LookupNode(id), "color", "green")
+	}
+	for _, k := range d.AddedEdges {
+		m.SetEdgeAttr(m.lookupEdge(k), "color", "green")
+	}
+	for i := range og.nodes {
+		n := &og.nodes[i]
+		if m.LookupNode(n.id) != nil {
+			continue
+		}
+		m.MakeNodeOrdered(n.id, og.attrList(n.attrs))
+		m.SetNodeAttr(m.LookupNode(n.id), "color", "red")
+	}
+	for _, k := range d.RemovedEdges {
+		m.AddEdgeOrdered(k.Src, k.Sink, og.attrList(og.lookupEdge(k).attrs))
+		m.SetEdgeAttr(m.lookupEdge(k), "color", "red")
+	}
+	return m
+}
@@ -0,0 +1,415 @@
+package zgr
+
+import (
+	"io"
+	"iter"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Topology is the read-only, index-based view of a graph's structure
+// and attributes shared by Graph and Frozen. Algorithms that only
+// need to walk the graph can be written against it and then run on
+// either form.
+type Topology interface {
+	GetNodeCount() uint32
+	LookupIndex(nid string) (uint32, bool)
+	NodeID(idx uint32) string
+	NodeAttr(idx uint32, key string) (string, bool)
+	EdgeAttr(eidx uint32, key string) (string, bool)
+	SuccessorIndices(idx uint32) iter.Seq[uint32]
+	PredecessorIndices(idx uint32) iter.Seq[uint32]
+	OutEdgeIndices(idx uint32) iter.Seq2[uint32, uint32]
+	InEdgeIndices(idx uint32) iter.Seq2[uint32, uint32]
+}
+
+// LookupIndex returns the index of the node with ID nid.
+func (g *Graph) LookupIndex(nid string) (uint32, bool) {
+	idx, ok := g.ntab[nid]
+	return idx, ok
+}
+
+// NodeID returns the ID of the node with index idx.
+func (g *Graph) NodeID(idx uint32) string {
+	return g.nodes[idx].id
+}
+
+// NodeAttr returns the value of attribute key on node idx, falling
+// back to the node defaults if the node doesn't set it.
+func (g *Graph) NodeAttr(idx uint32, key string) (string, bool) {
+	return lookupAttr(g.allattrs, g.nodes[idx].attrs, g.nattrs, key)
+}
+
+// EdgeAttr returns the value of attribute key on edge eidx, falling
+// back to the edge defaults if the edge doesn't set it.
+func (g *Graph) EdgeAttr(eidx uint32, key string) (string, bool) {
+	return lookupAttr(g.allattrs, g.edges[eidx].attrs, g.eattrs, key)
+}
+
+// lookupAttr returns the value of key in attrs, or failing that in
+// defs, both being lists of indices into allattrs.
+func lookupAttr(allattrs []Attr, attrs, defs []uint32, key string) (string, bool) {
+	for _, l := range [][]uint32{attrs, defs} {
+		for i := len(l) - 1; i >= 0; i-- {
+			if a := allattrs[l[i]]; a.key == key {
+				return a.val, true
+			}
+		}
+	}
+	return "", false
+}
+
+// SuccessorIndices returns an iterator over the sink indices of the
+// out-edges of node idx.
+func (g *Graph) SuccessorIndices(idx uint32) iter.Seq[uint32] {
+	return func(yield func(uint32) bool) {
+		for _, eid := range g.nodes[idx].outadjlist {
+			if !yield(g.edges[eid].sink) {
+				return
+			}
+		}
+	}
+}
+
+// PredecessorIndices returns an iterator over the source indices of
+// the in-edges of node idx.
+func (g *Graph) PredecessorIndices(idx uint32) iter.Seq[uint32] {
+	return func(yield func(uint32) bool) {
+		for _, eid := range g.nodes[idx].inadjlist {
+			if !yield(g.edges[eid].src) {
+				return
+			}
+		}
+	}
+}
+
+// OutEdgeIndices returns an iterator over the out-edges of node idx,
+// yielding each edge's index along with its sink.
+func (g *Graph) OutEdgeIndices(idx uint32) iter.Seq2[uint32, uint32] {
+	return func(yield func(uint32, uint32) bool) {
+		for _, eid := range g.nodes[idx].outadjlist {
+			if !yield(eid, g.edges[eid].sink) {
+				return
+			}
+		}
+	}
+}
+
+// InEdgeIndices returns an iterator over the in-edges of node idx,
+// yielding each edge's index along with its source.
+func (g *Graph) InEdgeIndices(idx uint32) iter.Seq2[uint32, uint32] {
+	return func(yield func(uint32, uint32) bool) {
+		for _, eid := range g.nodes[idx].inadjlist {
+			if !yield(eid, g.edges[eid].src) {
+				return
+			}
+		}
+	}
+}
+
+// Frozen is an immutable, compressed sparse row (CSR) form of a Graph,
+// for very large graphs that only need to be read. Node IDs are packed
+// into a single string, and adjacency and attribute lists are slices
+// of shared arrays indexed by per-node (or per-edge) offsets, so the
+// per-node overhead is a handful of integers rather than several
+// slices and a map entry. Node and edge indices are the same as in the
+// Graph it was built from.
+//
+// A Frozen never changes once built, so all of its methods are safe
+// for concurrent use by multiple goroutines. It is meant to serve as a
+// snapshot of a large graph shared by many readers.
+type Frozen struct {
+	name       string
+	strict     bool
+	undirected bool
+
+	ids   string   // all node IDs, concatenated
+	idoff []uint32 // node i's ID is ids[idoff[i]:idoff[i+1]]
+	byid  []uint32 // node indices sorted by ID, for lookup
+
+	src, sink []uint32 // endpoints of each edge
+	outoff    []uint32 // out-edges of node i are outadj[outoff[i]:outoff[i+1]]
+	outadj    []uint32
+	inoff     []uint32 // likewise for in-edges
+	inadj     []uint32
+
+	allattrs []Attr
+	attrs    []uint32
+	nattrs   []uint32
+	eattrs   []uint32
+	naoff    []uint32 // attributes of node i are naidx[naoff[i]:naoff[i+1]]
+	naidx    []uint32
+	eaoff    []uint32 // likewise for edges
+	eaidx    []uint32
+}
+
+// Freeze returns a Frozen copy of g. Later changes to g are not
+// reflected in the result.
+func (g *Graph) Freeze() *Frozen {
+	nn, ne := len(g.nodes), len(g.edges)
+	f := &Frozen{
+		name:       g.name,
+		strict:     g.strict,
+		undirected: g.undirected,
+		idoff:      make([]uint32, nn+1),
+		byid:       make([]uint32, nn),
+		src:        make([]uint32, ne),
+		sink:       make([]uint32, ne),
+		outoff:     make([]uint32, nn+1),
+		outadj:     make([]uint32, 0, ne),
+		inoff:      make([]uint32, nn+1),
+		inadj:      make([]uint32, 0, ne),
+		naoff:      make([]uint32, nn+1),
+		eaoff:      make([]uint32, ne+1),
+		attrs:      slices.Clone(g.attrs),
+		nattrs:     slices.Clone(g.nattrs),
+		eattrs:     slices.Clone(g.eattrs),
+	}
+
+	// Only keep the attributes that are still referenced; setAttr can
+	// leave stale entries behind in g.allattrs.
+	remap := make(map[uint32]uint32)
+	intern := func(l []uint32) []uint32 {
+		for i, at := range l {
+			nat, ok := remap[at]
+			if !ok {
+				nat = uint32(len(f.allattrs))
+				f.allattrs = append(f.allattrs, g.allattrs[at])
+				remap[at] = nat
+			}
+			l[i] = nat
+		}
+		return l
+	}
+	intern(f.attrs)
+	intern(f.nattrs)
+	intern(f.eattrs)
+
+	var sb strings.Builder
+	for i := range g.nodes {
+		n := &g.nodes[i]
+		sb.WriteString(n.id)
+		f.idoff[i+1] = uint32(sb.Len())
+		f.byid[i] = uint32(i)
+		f.outadj = append(f.outadj, n.outadjlist...)
+		f.outoff[i+1] = uint32(len(f.outadj))
+		f.inadj = append(f.inadj, n.inadjlist...)
+		f.inoff[i+1] = uint32(len(f.inadj))
+		f.naidx = append(f.naidx, n.attrs...)
+		f.naoff[i+1] = uint32(len(f.naidx))
+	}
+	intern(f.naidx)
+	f.ids = sb.String()
+	sort.Slice(f.byid, func(i, j int) bool {
+		return f.NodeID(f.byid[i]) < f.NodeID(f.byid[j])
+	})
+	for i := range g.edges {
+		e := &g.edges[i]
+		f.src[i], f.sink[i] = e.src, e.sink
+		f.eaidx = append(f.eaidx, e.attrs...)
+		f.eaoff[i+1] = uint32(len(f.eaidx))
+	}
+	intern(f.eaidx)
+	return f
+}
+
+func (f *Frozen) Name() string {
+	return f.name
+}
+
+func (f *Frozen) Strict() bool {
+	return f.strict
+}
+
+func (f *Frozen) Directed() bool {
+	return !f.undirected
+}
+
+func (f *Frozen) GetNodeCount() uint32 {
+	return uint32(len(f.idoff) - 1)
+}
+
+func (f *Frozen) GetEdgeCount() uint32 {
+	return uint32(len(f.src))
+}
+
+// NodeID returns the ID of the node with index idx.
+func (f *Frozen) NodeID(idx uint32) string {
+	return f.ids[f.idoff[idx]:f.idoff[idx+1]]
+}
+
+// LookupIndex returns the index of the node with ID nid.
+func (f *Frozen) LookupIndex(nid string) (uint32, bool) {
+	i := sort.Search(len(f.byid), func(i int) bool {
+		return f.NodeID(f.byid[i]) >= nid
+	})
+	if i < len(f.byid) && f.NodeID(f.byid[i]) == nid {
+		return f.byid[i], true
+	}
+	return 0, false
+}
+
+// NodeAttr returns the value of attribute key on node idx, falling
+// back to the node defaults if the node doesn't set it.
+func (f *Frozen) NodeAttr(idx uint32, key string) (string, bool) {
+	return lookupAttr(f.allattrs, f.naidx[f.naoff[idx]:f.naoff[idx+1]], f.nattrs, key)
+}
+
+// EdgeAttr returns the value of attribute key on edge eidx, falling
+// back to the edge defaults if the edge doesn't set it.
+func (f *Frozen) EdgeAttr(eidx uint32, key string) (string, bool) {
+	return lookupAttr(f.allattrs, f.eaidx[f.eaoff[eidx]:f.eaoff[eidx+1]], f.eattrs, key)
+}
+
+// GetEndpoints returns the source and sink indices of edge eidx.
+func (f *Frozen) GetEndpoints(eidx uint32) (uint32, uint32) {
+	return f.src[eidx], f.sink[eidx]
+}
+
+// OutEdges returns the indices of the out-edges of node idx. The
+// result shares storage with f and must not be modified.
+func (f *Frozen) OutEdges(idx uint32) []uint32 {
+	return f.outadj[f.outoff[idx]:f.outoff[idx+1]]
+}
+
+// InEdges returns the indices of the in-edges of node idx. The result
+// shares storage with f and must not be modified.
+func (f *Frozen) InEdges(idx uint32) []uint32 {
+	return f.inadj[f.inoff[idx]:f.inoff[idx+1]]
+}
+
+// SuccessorIndices returns an iterator over the sink indices of the
+// out-edges of node idx.
+func (f *Frozen) SuccessorIndices(idx uint32) iter.Seq[uint32] {
+	return func(yield func(uint32) bool) {
+		for _, eid := range f.OutEdges(idx) {
+			if !yield(f.sink[eid]) {
+				return
+			}
+		}
+	}
+}
+
+// PredecessorIndices returns an iterator over the source indices of
+// the in-edges of node idx.
+func (f *Frozen) PredecessorIndices(idx uint32) iter.Seq[uint32] {
+	return func(yield func(uint32) bool) {
+		for _, eid := range f.InEdges(idx) {
+			if !yield(f.src[eid]) {
+				return
+			}
+		}
+	}
+}
+
+// OutEdgeIndices returns an iterator over the out-edges of node idx,
+// yielding each edge's index along with its sink.
+func (f *Frozen) OutEdgeIndices(idx uint32) iter.Seq2[uint32, uint32] {
+	return func(yield func(uint32, uint32) bool) {
+		for _, eid := range f.OutEdges(idx) {
+			if !yield(eid, f.sink[eid]) {
+				return
+			}
+		}
+	}
+}
+
+// InEdgeIndices returns an iterator over the in-edges of node idx,
+// yielding each edge's index along with its source.
+func (f *Frozen) InEdgeIndices(idx uint32) iter.Seq2[uint32, uint32] {
+	return func(yield func(uint32, uint32) bool) {
+		for _, eid := range f.InEdges(idx) {
+			if !yield(eid, f.src[eid]) {
+				return
+			}
+		}
+	}
+}
+
+// FindEdge returns the index of the edge from node src to node sink,
+// if there is one.
+func (f *Frozen) FindEdge(src, sink uint32) (uint32, bool) {
+	for _, eid := range f.OutEdges(src) {
+		if f.sink[eid] == sink {
+			return eid, true
+		}
+	}
+	return 0, false
+}
+
+func (f *Frozen) attrList(attrs []uint32) []Attr {
+	res := make([]Attr, 0, len(attrs))
+	for _, at := range attrs {
+		res = append(res, f.allattrs[at])
+	}
+	return res
+}
+
+func (f *Frozen) GetAttrsOrdered() []Attr {
+	return f.attrList(f.attrs)
+}
+
+func (f *Frozen) GetNodeDefaultsOrdered() []Attr {
+	return f.attrList(f.nattrs)
+}
+
+func (f *Frozen) GetEdgeDefaultsOrdered() []Attr {
+	return f.attrList(f.eattrs)
+}
+
+// GetNodeAttrsOrdered returns the attributes of node idx in the order
+// they were added.
+func (f *Frozen) GetNodeAttrsOrdered(idx uint32) []Attr {
+	return f.attrList(f.naidx[f.naoff[idx]:f.naoff[idx+1]])
+}
+
+// GetEdgeAttrsOrdered returns the attributes of edge eidx in the order
+// they were added.
+func (f *Frozen) GetEdgeAttrsOrdered(eidx uint32) []Attr {
+	return f.attrList(f.eaidx[f.eaoff[eidx]:f.eaoff[eidx+1]])
+}
+
+// Subgraph returns a new, mutable Graph containing the nodes of f
+// selected by include (all nodes if include is nil), along with the
+// edges between them, in the same way as Graph.Subgraph. This is the
+// usual way to write out a slice of a frozen graph.
+func (f *Frozen) Subgraph(include map[uint32]bool) *Graph {
+	sg := NewGraph()
+	sg.name = f.name
+	sg.strict = f.strict
+	sg.undirected = f.undirected
+	sg.attrs = sg.populateAttrs(f.GetAttrsOrdered())
+	sg.nattrs = sg.populateAttrs(f.GetNodeDefaultsOrdered())
+	sg.eattrs = sg.populateAttrs(f.GetEdgeDefaultsOrdered())
+	keep := func(idx uint32) bool {
+		return include == nil || include[idx]
+	}
+	for i := uint32(0); i < f.GetNodeCount(); i++ {
+		if keep(i) {
+			sg.MakeNodeOrdered(f.NodeID(i), f.GetNodeAttrsOrdered(i))
+		}
+	}
+	for i := uint32(0); i < f.GetEdgeCount(); i++ {
+		if keep(f.src[i]) && keep(f.sink[i]) {
+			sg.AddEdgeOrdered(f.NodeID(f.src[i]), f.NodeID(f.sink[i]),
+				f.GetEdgeAttrsOrdered(i))
+		}
+	}
+	return sg
+}
+
+// Write writes the nodes of f selected by toinclude (all nodes if
+// toinclude is nil), along with the edges between them, in DOT format,
+// as Graph.Write does.
+func (f *Frozen) Write(w io.Writer, toinclude map[uint32]bool) error {
+	return f.WriteWithOptions(w, toinclude, WriteOptions{})
+}
+
+// WriteWithOptions is like Graph.WriteWithOptions. The selected nodes
+// are first copied out into a private Graph, so the cost is
+// proportional to the size of the slice being written plus a pass over
+// the edges of f.
+func (f *Frozen) WriteWithOptions(w io.Writer, toinclude map[uint32]bool, opts WriteOptions) error {
+	return f.Subgraph(toinclude).WriteWithOptions(w, nil, opts)
+}
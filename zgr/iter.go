@@ -0,0 +1,82 @@
+package zgr
+
+import "iter"
+
+// Nodes returns an iterator over the nodes of g in index order.
+func (g *Graph) Nodes() iter.Seq[*Node] {
+	return func(yield func(*Node) bool) {
+		for i := range g.nodes {
+			if !yield(&g.nodes[i]) {
+				return
+			}
+		}
+	}
+}
+
+// Edges returns an iterator over the edges of g, yielding each edge
+// along with its index.
+func (g *Graph) Edges() iter.Seq2[uint32, *Edge] {
+	return func(yield func(uint32, *Edge) bool) {
+		for i := range g.edges {
+			if !yield(uint32(i), &g.edges[i]) {
+				return
+			}
+		}
+	}
+}
+
+func (g *Graph) edgeSeq(eids []uint32) iter.Seq2[uint32, *Edge] {
+	return func(yield func(uint32, *Edge) bool) {
+		for _, eid := range eids {
+			if !yield(eid, &g.edges[eid]) {
+				return
+			}
+		}
+	}
+}
+
+// OutEdges returns an iterator over the out-edges of n (index, edge).
+func (g *Graph) OutEdges(n *Node) iter.Seq2[uint32, *Edge] {
+	return g.edgeSeq(n.outadjlist)
+}
+
+// InEdges returns an iterator over the in-edges of n (index, edge).
+func (g *Graph) InEdges(n *Node) iter.Seq2[uint32, *Edge] {
+	return g.edgeSeq(n.inadjlist)
+}
+
+// Successors returns an iterator over the sinks of n's out-edges.
+func (g *Graph) Successors(n *Node) iter.Seq[*Node] {
+	return func(yield func(*Node) bool) {
+		for _, eid := range n.outadjlist {
+			if !yield(&g.nodes[g.edges[eid].sink]) {
+				return
+			}
+		}
+	}
+}
+
+// Predecessors returns an iterator over the sources of n's in-edges.
+func (g *Graph) Predecessors(n *Node) iter.Seq[*Node] {
+	return func(yield func(*Node) bool) {
+		for _, eid := range n.inadjlist {
+			if !yield(&g.nodes[g.edges[eid].src]) {
+				return
+			}
+		}
+	}
+}
+
+// FindEdge returns the index of the edge from node index src to node
+// index sink, if there is one.
+func (g *Graph) FindEdge(src, sink uint32) (uint32, bool) {
+	eidx, ok := g.etab[npair{src: src, sink: sink}]
+	return uint32(eidx), ok
+}
+
+// HasEdge reports whether g has an edge from node index src to node
+// index sink.
+func (g *Graph) HasEdge(src, sink uint32) bool {
+	_, ok := g.etab[npair{src: src, sink: sink}]
+	return ok
+}
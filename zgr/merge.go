@@ -0,0 +1,162 @@
+package zgr
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ConflictPolicy says what Merge does when the same attribute has
+// different values in two of the graphs being merged.
+type ConflictPolicy uint8
+
+const (
+	// FirstWins keeps the value from the earliest graph.
+	FirstWins ConflictPolicy = 0
+	// LastWins keeps the value from the latest graph.
+	LastWins ConflictPolicy = 1
+	// ErrorOnConflict makes Merge fail.
+	ErrorOnConflict ConflictPolicy = 2
+	// Concatenate joins the distinct values into a single quoted
+	// string, in graph order.
+	Concatenate ConflictPolicy = 3
+)
+
+type MergeOptions struct {
+	Policy ConflictPolicy
+
+	// Sep separates the values joined by Concatenate; "," if empty.
+	Sep string
+}
+
+type mergeKey struct {
+	what, key string
+}
+
+type merger struct {
+	opts MergeOptions
+	r    *Graph
+	seen map[mergeKey][]string
+}
+
+// resolve returns the value to store for key on the element described
+// by what, given the current value cur and the incoming value v.
+func (m *merger) resolve(what, key, cur, v string) (string, error) {
+	if cur == v {
+		return cur, nil
+	}
+	switch m.opts.Policy {
+	case FirstWins:
+		return cur, nil
+	case LastWins:
+		return v, nil
+	case ErrorOnConflict:
+		s := fmt.Sprintf("Merge: conflicting values for attribute %s of %s: %s vs %s",
+			key, what, cur, v)
+		return "", errors.New(s)
+	}
+	mk := mergeKey{what: what, key: key}
+	vals := m.seen[mk]
+	if vals == nil {
+		vals = []string{cur}
+	}
+	if !slices.Contains(vals, v) {
+		vals = append(vals, v)
+	}
+	m.seen[mk] = vals
+	sep := m.opts.Sep
+	if sep == "" {
+		sep = ","
+	}
+	parts := make([]string, len(vals))
+	for i, val := range vals {
+		parts[i] = Unquote(val)
+	}
+	return Quote(strings.Join(parts, sep)), nil
+}
+
+// mergeAttrs folds the attributes from src (belonging to graph sg)
+// into the attribute list dst of the result graph.
+func (m *merger) mergeAttrs(what string, dst []uint32, sg *Graph, src []uint32) ([]uint32, error) {
+	r := m.r
+	for _, at := range src {
+		a := sg.allattrs[at]
+		cur := ""
+		found := false
+		for _, dat := range dst {
+			if r.allattrs[dat].key == a.key {
+				cur = r.allattrs[dat].val
+				found = true
+				break
+			}
+		}
+		v := a.val
+		if found {
+			var err error
+			if v, err = m.resolve(what, a.key, cur, a.val); err != nil {
+				return nil, err
+			}
+			if v == cur {
+				continue
+			}
+		}
+		dst = r.setAttr(dst, a.key, v)
+	}
+	return dst, nil
+}
+
+// Merge returns the union of the specified graphs. Nodes are matched by
+// ID and edges by the IDs of their endpoints; elements keep the order
+// in which they are first seen. Attributes present in more than one
+// graph are combined according to opts.Policy. The name and kind of the
+// result come from the first graph, and mixing directed and undirected
+// graphs is an error.
+func Merge(opts MergeOptions, graphs ...*Graph) (*Graph, error) {
+	r := NewGraph()
+	m := &merger{opts: opts, r: r, seen: make(map[mergeKey][]string)}
+	var err error
+	for gi, g := range graphs {
+		if gi == 0 {
+			r.name = g.name
+			r.strict = g.strict
+			r.undirected = g.undirected
+		} else if g.undirected != r.undirected {
+			s := fmt.Sprintf("Merge: graph %d is not the same kind as graph 0", gi)
+			return nil, errors.New(s)
+		}
+		if r.attrs, err = m.mergeAttrs("graph", r.attrs, g, g.attrs); err != nil {
+			return nil, err
+		}
+		if r.nattrs, err = m.mergeAttrs("node defaults", r.nattrs, g, g.nattrs); err != nil {
+			return nil, err
+		}
+		if r.eattrs, err = m.mergeAttrs("edge defaults", r.eattrs, g, g.eattrs); err != nil {
+			return nil, err
+		}
+		for i := range g.nodes {
+			n := &g.nodes[i]
+			rn := r.LookupNode(n.id)
+			if rn == nil {
+				r.MakeNodeOrdered(n.id, g.attrList(n.attrs))
+				continue
+			}
+			if rn.attrs, err = m.mergeAttrs("node "+n.id, rn.attrs, g, n.attrs); err != nil {
+				return nil, err
+			}
+			rn.label = r.attrMap(rn.attrs)["label"]
+		}
+		for i := range g.edges {
+			k := g.edgeKey(&g.edges[i])
+			re := r.lookupEdge(k)
+			if re == nil {
+				r.AddEdgeOrdered(k.Src, k.Sink, g.attrList(g.edges[i].attrs))
+				continue
+			}
+			if re.attrs, err = m.mergeAttrs("edge "+k.String(), re.attrs, g, g.edges[i].attrs); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return r, nil
+}
@@ -0,0 +1,96 @@
+package zgr
+
+import "strings"
+
+// Node IDs and attribute values are stored exactly as they appeared in
+// the DOT source, so string values carry their surrounding quotes.
+// Code that exchanges values with formats holding plain strings (JSON,
+// GraphML, Mermaid) or that matches them against user input converts
+// with Quote and Unquote, which undo each other. QuoteRaw is for values
+// that are already in DOT syntax and only need to end up quoted.
+//
+// Only \\ and \" are escapes of the plain string; other backslash
+// sequences (\n, \l and so on) mean something to Graphviz and are passed
+// through both ways. So a DOT label "a\nb" converts to the plain string
+// a\nb and back, and a plain string can hold any text, including quotes
+// and backslashes.
+
+func isQuoted(s string) bool {
+	return len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"'
+}
+
+// isHTML reports whether s is a DOT HTML string, such as <<b>x</b>>.
+func isHTML(s string) bool {
+	return len(s) >= 2 && s[0] == '<' && s[len(s)-1] == '>'
+}
+
+// Quote returns the plain string s as a DOT quoted string. Every '"'
+// is escaped, as is any backslash that would otherwise read as \\ or
+// \" or escape the closing quote; Unquote(Quote(s)) == s for all s.
+func Quote(s string) string {
+	var sb strings.Builder
+	sb.Grow(len(s) + 2)
+	sb.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' && (i+1 == len(s) || s[i+1] == '\\' || s[i+1] == '"'):
+			sb.WriteString(`\\`)
+		case c == '"':
+			sb.WriteString(`\"`)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+// QuoteRaw returns the raw DOT value s as a quoted string. A string that
+// is already quoted is returned unchanged. Otherwise any '"' not already
+// escaped is escaped, other backslash sequences are kept, and a trailing
+// backslash is doubled so that it can't escape the closing quote.
+func QuoteRaw(s string) string {
+	if isQuoted(s) {
+		return s
+	}
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' && i+1 < len(s):
+			sb.WriteByte(c)
+			sb.WriteByte(s[i+1])
+			i++
+		case c == '\\':
+			sb.WriteString(`\\`)
+		case c == '"':
+			sb.WriteString(`\"`)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+// Unquote strips the surrounding double quotes from s, if present, and
+// turns \\ and \" into \ and ". Other escape sequences are left alone.
+// A string that is not quoted is returned unchanged.
+func Unquote(s string) string {
+	if !isQuoted(s) {
+		return s
+	}
+	s = s[1 : len(s)-1]
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var sb strings.Builder
+	sb.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && (s[i+1] == '\\' || s[i+1] == '"') {
+			i++
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
@@ -0,0 +1,311 @@
+package zgr
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Order selects how Write lays out statements and attribute lists.
+type Order uint8
+
+const (
+	// IndexOrder emits nodes in index order, followed by edges
+	// grouped by source node in adjacency-list order, with attributes
+	// sorted alphabetically. This is what Write has always produced.
+	IndexOrder Order = 0
+	// SortedOrder emits nodes sorted by ID, edges sorted by endpoint
+	// IDs, and attributes sorted alphabetically, giving a canonical
+	// form regardless of how the graph was built.
+	SortedOrder Order = 1
+	// InputOrder emits nodes, edges and attributes in the order in
+	// which they were added to the graph, so that a parsed and
+	// rewritten graph diffs cleanly against its input.
+	InputOrder Order = 2
+)
+
+// CommaStyle selects the separator used between attributes in a
+// bracketed attribute list.
+type CommaStyle uint8
+
+const (
+	// LegacyCommas separates node attributes with commas and edge
+	// attributes with spaces only.
+	LegacyCommas CommaStyle = 0
+	// AllCommas separates all bracketed attributes with commas.
+	AllCommas CommaStyle = 1
+	// NoCommas separates all bracketed attributes with spaces only.
+	NoCommas CommaStyle = 2
+)
+
+// QuotePolicy selects how node IDs and attribute values are quoted.
+type QuotePolicy uint8
+
+const (
+	// QuoteAsIs writes IDs and values exactly as they were stored.
+	QuoteAsIs QuotePolicy = 0
+	// QuoteAll wraps every ID and value other than HTML strings in
+	// double quotes.
+	QuoteAll QuotePolicy = 1
+	// QuoteMinimal strips quotes from attribute values that don't need
+	// them. Node IDs are left alone, since grparser requires them to
+	// be quoted strings.
+	QuoteMinimal QuotePolicy = 2
+)
+
+type WriteOptions struct {
+	Order Order
+
+	// Indent is prepended to each statement inside the graph body.
+	Indent string
+
+	// Semicolons terminates each statement with a ';'.
+	Semicolons bool
+
+	Commas  CommaStyle
+	Quoting QuotePolicy
+
+	// WrapWidth, if non-zero, breaks attribute lists across lines so
+	// that statements stay within this many columns where possible.
+	WrapWidth int
+
+	// EmitDefaults writes the graph's "node [...]" and "edge [...]"
+	// default attribute blocks.
+	EmitDefaults bool
+}
+
+type comdisp uint8
+
+const (
+	noCommas  comdisp = 0
+	yesCommas comdisp = 1
+)
+
+type brackdisp uint8
+
+const (
+	noBrackets  brackdisp = 0
+	yesBrackets brackdisp = 1
+)
+
+type dotWriter struct {
+	g    *Graph
+	bw   *bufio.Writer
+	opts WriteOptions
+}
+
+var plainIdRe = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*|[0-9]+(\.[0-9]+)?)$`)
+
+var dotKeywords = map[string]bool{
+	"node": true, "edge": true, "graph": true,
+	"digraph": true, "subgraph": true, "strict": true,
+}
+
+func (dw *dotWriter) quote(s string, isId bool) string {
+	switch dw.opts.Quoting {
+	case QuoteAll:
+		// HTML strings lose their meaning if quoted.
+		if isHTML(s) {
+			return s
+		}
+		return QuoteRaw(s)
+	case QuoteMinimal:
+		if isId || !isQuoted(s) {
+			return s
+		}
+		inner := s[1 : len(s)-1]
+		if plainIdRe.MatchString(inner) && !dotKeywords[strings.ToLower(inner)] {
+			return inner
+		}
+	}
+	return s
+}
+
+func (dw *dotWriter) commas(com comdisp) bool {
+	switch dw.opts.Commas {
+	case AllCommas:
+		return true
+	case NoCommas:
+		return false
+	}
+	return com == yesCommas
+}
+
+// stmt writes a single statement consisting of head followed by the
+// specified attributes, wrapping the attribute list if requested.
+func (dw *dotWriter) stmt(head string, attrs []uint32, com comdisp, brack brackdisp) {
+	var sb strings.Builder
+	sb.WriteString(dw.opts.Indent)
+	sb.WriteString(head)
+	col := sb.Len()
+	if len(attrs) != 0 {
+		g := dw.g
+		atlist := []string{}
+		for _, idx := range attrs {
+			a := g.allattrs[idx]
+			atlist = append(atlist, fmt.Sprintf("%s=%s", a.key, dw.quote(a.val, false)))
+		}
+		if dw.opts.Order != InputOrder {
+			sort.Strings(atlist)
+		}
+		if brack == yesBrackets {
+			sb.WriteString(" [")
+			col += 2
+		}
+		// Commas are only legal inside brackets.
+		sep := ""
+		if brack == yesBrackets && dw.commas(com) {
+			sep = ","
+		}
+		cont := dw.opts.Indent + "    "
+		for i, atv := range atlist {
+			if i != 0 {
+				sb.WriteString(sep)
+				col += len(sep)
+				if dw.opts.WrapWidth > 0 && col+1+len(atv) > dw.opts.WrapWidth {
+					sb.WriteString("\n")
+					sb.WriteString(cont)
+					col = len(cont)
+				} else {
+					sb.WriteString(" ")
+					col++
+				}
+			}
+			sb.WriteString(atv)
+			col += len(atv)
+		}
+		if brack == yesBrackets {
+			sb.WriteString("]")
+		}
+	}
+	if dw.opts.Semicolons {
+		sb.WriteString(";")
+	}
+	sb.WriteString("\n")
+	dw.bw.WriteString(sb.String())
+}
+
+func (g *Graph) Write(w io.Writer, toinclude map[uint32]bool) error {
+	return g.WriteWithOptions(w, toinclude, WriteOptions{})
+}
+
+func (dw *dotWriter) header() string {
+	g := dw.g
+	var sb strings.Builder
+	if g.strict {
+		sb.WriteString("strict ")
+	}
+	if g.undirected {
+		sb.WriteString("graph ")
+	} else {
+		sb.WriteString("digraph ")
+	}
+	name := g.name
+	if name == "" {
+		name = "G"
+	}
+	sb.WriteString(dw.quote(name, true))
+	sb.WriteString(" {\n")
+	return sb.String()
+}
+
+// writeOrder returns the node and edge indices to visit when writing
+// the graph with the specified ordering.
+func (g *Graph) writeOrder(ord Order) ([]uint32, []uint32) {
+	nodes := make([]uint32, len(g.nodes))
+	for i := range g.nodes {
+		nodes[i] = uint32(i)
+	}
+	edges := make([]uint32, 0, len(g.edges))
+	switch ord {
+	case InputOrder:
+		for i := range g.edges {
+			edges = append(edges, uint32(i))
+		}
+		return nodes, edges
+	case IndexOrder:
+		for _, n := range g.nodes {
+			edges = append(edges, n.outadjlist...)
+		}
+		return nodes, edges
+	}
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return g.nodes[nodes[i]].id < g.nodes[nodes[j]].id
+	})
+	for _, nid := range nodes {
+		edges = append(edges, g.nodes[nid].outadjlist...)
+	}
+	sort.SliceStable(edges, func(i, j int) bool {
+		ei, ej := g.edges[edges[i]], g.edges[edges[j]]
+		if ei.src != ej.src {
+			return g.nodes[ei.src].id < g.nodes[ej.src].id
+		}
+		return g.nodes[ei.sink].id < g.nodes[ej.sink].id
+	})
+	return nodes, edges
+}
+
+// WriteWithOptions writes the nodes of g selected by toinclude (all
+// nodes if toinclude is nil), along with the edges between them, in
+// DOT format. It returns the first error encountered writing to w.
+func (g *Graph) WriteWithOptions(w io.Writer, toinclude map[uint32]bool, opts WriteOptions) error {
+	dw := &dotWriter{g: g, bw: bufio.NewWriter(w), opts: opts}
+	dw.bw.WriteString(dw.header())
+
+	// Attrs for the graph itself.
+	if len(g.attrs) != 0 {
+		dw.stmt("", g.attrs, noCommas, noBrackets)
+	}
+
+	// Default attribute blocks.
+	if opts.EmitDefaults {
+		if len(g.nattrs) != 0 {
+			dw.stmt("node", g.nattrs, yesCommas, yesBrackets)
+		}
+		if len(g.eattrs) != 0 {
+			dw.stmt("edge", g.eattrs, noCommas, yesBrackets)
+		}
+	}
+
+	emit := func(x uint32) bool {
+		if toinclude == nil {
+			return true
+		}
+		return toinclude[x]
+	}
+
+	edgeop := "->"
+	if g.undirected {
+		edgeop = "--"
+	}
+	nodes, edges := g.writeOrder(opts.Order)
+
+	// Nodes
+	for _, nid := range nodes {
+		if !emit(nid) {
+			continue
+		}
+		n := g.nodes[nid]
+		dw.stmt(fmt.Sprintf("%s ", dw.quote(n.id, true)), n.attrs, yesCommas, yesBrackets)
+	}
+
+	// Edges
+	for _, eid := range edges {
+		e := g.edges[eid]
+		if !emit(e.src) || !emit(e.sink) {
+			continue
+		}
+		head := fmt.Sprintf("%s %s %s", dw.quote(g.nodes[e.src].id, true),
+			edgeop, dw.quote(g.nodes[e.sink].id, true))
+		dw.stmt(head, e.attrs, noCommas, yesBrackets)
+	}
+
+	dw.bw.WriteString("}\n")
+
+	// bufio.Writer holds on to the first write error it sees, so this
+	// reports any failure from the writes above as well.
+	return dw.bw.Flush()
+}
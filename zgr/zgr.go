@@ -1,10 +1,10 @@
 package zgr
 
 import (
-	"bufio"
 	"errors"
 	"fmt"
-	"io"
+	"maps"
+	"slices"
 	"sort"
 	"strings"
 )
@@ -30,14 +30,25 @@ type Attr struct {
 	key, val string
 }
 
+// Graph is a mutable graph. It does no locking of its own: methods
+// that only read the graph (lookups, iterators, attribute getters,
+// Write and friends) may be called from several goroutines at once,
+// but only as long as nothing modifies the graph at the same time.
+// Graphs that are shared by long-lived concurrent readers should be
+// converted with Freeze instead.
 type Graph struct {
-	nodes    []Node
-	edges    []Edge
-	ntab     map[string]uint32
-	etab     map[npair]int
-	attrs    []uint32
-	allattrs []Attr
-	attrtab  map[Attr]uint32
+	name       string
+	strict     bool
+	undirected bool
+	nodes      []Node
+	edges      []Edge
+	ntab       map[string]uint32
+	etab       map[npair]int
+	attrs      []uint32
+	nattrs     []uint32
+	eattrs     []uint32
+	allattrs   []Attr
+	attrtab    map[Attr]uint32
 }
 
 func NewGraph() *Graph {
@@ -48,34 +59,142 @@ func NewGraph() *Graph {
 	}
 }
 
-func (g *Graph) populateAttrs(attrs map[string]string) []uint32 {
-	res := []uint32{}
+func NewAttr(key, val string) Attr {
+	return Attr{key: key, val: val}
+}
+
+func (a Attr) Key() string {
+	return a.key
+}
+
+func (a Attr) Val() string {
+	return a.val
+}
+
+// sortedAttrs converts an attribute map into a list ordered by key, so
+// that graphs built from maps come out the same way every time.
+func sortedAttrs(attrs map[string]string) []Attr {
+	res := make([]Attr, 0, len(attrs))
 	for k, v := range attrs {
-		a := Attr{key: k, val: v}
+		res = append(res, Attr{key: k, val: v})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].key < res[j].key })
+	return res
+}
+
+func (g *Graph) populateAttrs(attrs []Attr) []uint32 {
+	res := []uint32{}
+	for _, a := range attrs {
 		var idx uint32
 		var ok bool
 		if idx, ok = g.attrtab[a]; !ok {
 			idx = uint32(len(g.allattrs))
 			g.allattrs = append(g.allattrs, a)
+			g.attrtab[a] = idx
 		}
 		res = append(res, idx)
 	}
 	return res
 }
 
+func (g *Graph) SetName(name string) {
+	g.name = name
+}
+
+func (g *Graph) Name() string {
+	return g.name
+}
+
+func (g *Graph) SetStrict(strict bool) {
+	g.strict = strict
+}
+
+func (g *Graph) Strict() bool {
+	return g.strict
+}
+
+func (g *Graph) SetDirected(directed bool) {
+	g.undirected = !directed
+}
+
+func (g *Graph) Directed() bool {
+	return !g.undirected
+}
+
 func (g *Graph) SetAttrs(attrs map[string]string) error {
+	return g.SetAttrsOrdered(sortedAttrs(attrs))
+}
+
+// SetAttrsOrdered is like SetAttrs, but preserves the order of attrs
+// when the graph is written with InputOrder.
+func (g *Graph) SetAttrsOrdered(attrs []Attr) error {
 	g.attrs = g.populateAttrs(attrs)
 	return nil
 }
 
+// SetNodeDefaults records the attributes of a "node [...]" default
+// block for the graph.
+func (g *Graph) SetNodeDefaults(attrs []Attr) {
+	g.nattrs = g.populateAttrs(attrs)
+}
+
+// SetEdgeDefaults records the attributes of an "edge [...]" default
+// block for the graph.
+func (g *Graph) SetEdgeDefaults(attrs []Attr) {
+	g.eattrs = g.populateAttrs(attrs)
+}
+
+func (g *Graph) GetNodeDefaults() map[string]string {
+	return g.attrMap(g.nattrs)
+}
+
+func (g *Graph) GetEdgeDefaults() map[string]string {
+	return g.attrMap(g.eattrs)
+}
+
+func (g *Graph) GetAttrs() map[string]string {
+	return g.attrMap(g.attrs)
+}
+
+// GetAttrsOrdered returns the graph attributes in the order they were
+// added.
+func (g *Graph) GetAttrsOrdered() []Attr {
+	return g.attrList(g.attrs)
+}
+
+func (g *Graph) GetNodeDefaultsOrdered() []Attr {
+	return g.attrList(g.nattrs)
+}
+
+func (g *Graph) GetEdgeDefaultsOrdered() []Attr {
+	return g.attrList(g.eattrs)
+}
+
+func (g *Graph) attrMap(attrs []uint32) map[string]string {
+	res := make(map[string]string)
+	for _, at := range attrs {
+		a := g.allattrs[at]
+		res[a.key] = a.val
+	}
+	return res
+}
+
 func (g *Graph) MakeNode(nid string, attrs map[string]string) error {
+	return g.MakeNodeOrdered(nid, sortedAttrs(attrs))
+}
+
+// MakeNodeOrdered is like MakeNode, but preserves the order of attrs
+// when the graph is written with InputOrder.
+func (g *Graph) MakeNodeOrdered(nid string, attrs []Attr) error {
 	if _, ok := g.ntab[nid]; ok {
 		return errors.New(fmt.Sprintf("MakeNode: collision on node id %s", nid))
 	}
 	res := g.populateAttrs(attrs)
 	nlabel := ""
-	if lab, ok := attrs["label"]; ok {
-		nlabel = lab
+	for _, a := range attrs {
+		if a.key == "label" {
+			nlabel = a.val
+		}
 	}
 	n := Node{id: nid, label: nlabel, idx: uint32(len(g.nodes)), attrs: res}
 	g.ntab[nid] = uint32(n.idx)
@@ -84,6 +203,12 @@ func (g *Graph) MakeNode(nid string, attrs map[string]string) error {
 }
 
 func (g *Graph) AddEdge(src, sink string, attrs map[string]string) error {
+	return g.AddEdgeOrdered(src, sink, sortedAttrs(attrs))
+}
+
+// AddEdgeOrdered is like AddEdge, but preserves the order of attrs
+// when the graph is written with InputOrder.
+func (g *Graph) AddEdgeOrdered(src, sink string, attrs []Attr) error {
 	var srcid, sinkid uint32
 	var ok bool
 	if srcid, ok = g.ntab[src]; !ok {
@@ -120,7 +245,7 @@ func (g *Graph) SetEdgeAttrs(src, sink string, attrs map[string]string) error {
 	if !ok {
 		return errors.New(fmt.Sprintf("can't locate edge %q -> %q", src, sink))
 	}
-	g.edges[v].attrs = g.populateAttrs(attrs)
+	g.edges[v].attrs = g.populateAttrs(sortedAttrs(attrs))
 	return nil
 }
 
@@ -158,10 +283,17 @@ func (g *Graph) String() string {
 
 func (g *Graph) Transpose() *Graph {
 	tg := NewGraph()
+	tg.name = g.name
+	tg.strict = g.strict
+	tg.undirected = g.undirected
 	tg.attrs = g.attrs
-	tg.allattrs = g.allattrs
-	tg.attrtab = g.attrtab
-	tg.ntab = g.ntab
+	tg.nattrs = g.nattrs
+	tg.eattrs = g.eattrs
+	// Copy the tables rather than sharing them, so that later changes
+	// to one graph can't leave the other with dangling indices.
+	tg.allattrs = slices.Clone(g.allattrs)
+	tg.attrtab = maps.Clone(g.attrtab)
+	tg.ntab = maps.Clone(g.ntab)
 	for _, n := range g.nodes {
 		tn := n
 		tn.inadjlist = []uint32{}
@@ -174,6 +306,7 @@ func (g *Graph) Transpose() *Graph {
 		var te Edge = e
 		te.src = e.sink
 		te.sink = e.src
+		tg.etab[npair{src: te.src, sink: te.sink}] = i
 		tg.edges = append(tg.edges, te)
 	}
 	return tg
@@ -202,122 +335,129 @@ func (g *Graph) GetEdge(eidx uint32) *Edge {
 }
 
 func (g *Graph) GetEdgeAttrs(e *Edge) map[string]string {
-	res := make(map[string]string)
-	for _, at := range e.attrs {
-		a := g.allattrs[at]
-		res[a.key] = a.val
-	}
-	return res
-}
-
-func (g *Graph) GetEndpoints(e *Edge) (uint32, uint32) {
-	return e.src, e.sink
+	return g.attrMap(e.attrs)
 }
 
-func (g *Graph) GetNodeIndex(n *Node) uint32 {
-	return n.idx
+func (g *Graph) GetNodeAttrs(n *Node) map[string]string {
+	return g.attrMap(n.attrs)
 }
 
-func (g *Graph) LookupNode(nid string) *Node {
-	if idx, ok := g.ntab[nid]; ok {
-		return &g.nodes[idx]
+func (g *Graph) attrList(attrs []uint32) []Attr {
+	res := make([]Attr, 0, len(attrs))
+	for _, at := range attrs {
+		res = append(res, g.allattrs[at])
 	}
-	return nil
+	return res
 }
 
-func (g *Graph) GetNodeCount() uint32 {
-	return uint32(len(g.nodes))
+// GetEdgeAttrsOrdered returns the attributes of e in the order they
+// were added.
+func (g *Graph) GetEdgeAttrsOrdered(e *Edge) []Attr {
+	return g.attrList(e.attrs)
 }
 
-type comdisp uint8
-
-const (
-	noCommas  comdisp = 0
-	yesCommas comdisp = 1
-)
-
-type brackdisp uint8
-
-const (
-	noBrackets  brackdisp = 0
-	yesBrackets brackdisp = 1
-)
+// GetNodeAttrsOrdered returns the attributes of n in the order they
+// were added.
+func (g *Graph) GetNodeAttrsOrdered(n *Node) []Attr {
+	return g.attrList(n.attrs)
+}
 
-func (g *Graph) writeAttrs(bw *bufio.Writer, attrs []uint32, com comdisp, brack brackdisp) {
-	if len(attrs) == 0 {
-		return
-	}
-	if brack == yesBrackets {
-		bw.WriteString(" [")
-	}
-	first := true
-	atlist := []string{}
-	for _, idx := range attrs {
-		a := g.allattrs[idx]
-		atlist = append(atlist, fmt.Sprintf("%s=%s", a.key, a.val))
-	}
-	sort.Strings(atlist)
-	for _, atv := range atlist {
-		if !first {
-			if com == yesCommas {
-				bw.WriteString(",")
-			}
-			bw.WriteString(" ")
+// setAttr returns attrs with key set to val, replacing any existing
+// value for key in place.
+func (g *Graph) setAttr(attrs []uint32, key, val string) []uint32 {
+	nidx := g.populateAttrs([]Attr{{key: key, val: val}})[0]
+	res := make([]uint32, 0, len(attrs)+1)
+	found := false
+	for _, at := range attrs {
+		if g.allattrs[at].key == key {
+			at = nidx
+			found = true
 		}
-		first = false
-		bw.WriteString(fmt.Sprintf("%s", atv))
+		res = append(res, at)
 	}
-	if brack == yesBrackets {
-		bw.WriteString("]")
+	if !found {
+		res = append(res, nidx)
 	}
+	return res
 }
 
-func (g *Graph) Write(w io.Writer, toinclude map[uint32]bool) error {
-	bw := bufio.NewWriter(w)
-	bw.WriteString("digraph G {\n")
-
-	// Attrs for the graph itself.
-	if len(g.attrs) != 0 {
-		g.writeAttrs(bw, g.attrs, noCommas, noBrackets)
-		bw.WriteString("\n")
+// SetNodeAttr sets a single attribute on n, leaving the others alone.
+func (g *Graph) SetNodeAttr(n *Node, key, val string) {
+	n.attrs = g.setAttr(n.attrs, key, val)
+	if key == "label" {
+		n.label = val
 	}
+}
 
-	emit := func(x uint32) bool {
-		if toinclude == nil {
-			return true
-		}
-		return toinclude[x]
+// SetEdgeAttr sets a single attribute on e, leaving the others alone.
+func (g *Graph) SetEdgeAttr(e *Edge, key, val string) {
+	e.attrs = g.setAttr(e.attrs, key, val)
+}
+
+// Subgraph returns a new graph containing the nodes of g selected by
+// include (all nodes if include is nil, as for Write), along with the
+// edges between them. Nodes and edges keep their relative order and
+// attributes, and the graph-level attributes and defaults are copied
+// over.
+func (g *Graph) Subgraph(include map[uint32]bool) *Graph {
+	if include == nil {
+		return g.Filter(nil, nil)
 	}
+	return g.Filter(func(n *Node) bool { return include[n.idx] }, nil)
+}
 
-	// Nodes
-	for nid, n := range g.nodes {
-		if !emit(uint32(nid)) {
-			continue
+// Filter is a more general form of Subgraph. It returns a new graph
+// containing the nodes of g for which keepNode returns true, and the
+// edges between them for which keepEdge returns true. A nil function
+// keeps everything.
+func (g *Graph) Filter(keepNode func(n *Node) bool, keepEdge func(eid uint32, e *Edge) bool) *Graph {
+	sg := NewGraph()
+	sg.name = g.name
+	sg.strict = g.strict
+	sg.undirected = g.undirected
+	sg.attrs = sg.populateAttrs(g.attrList(g.attrs))
+	sg.nattrs = sg.populateAttrs(g.attrList(g.nattrs))
+	sg.eattrs = sg.populateAttrs(g.attrList(g.eattrs))
+	kept := make([]bool, len(g.nodes))
+	for i := range g.nodes {
+		n := &g.nodes[i]
+		if keepNode == nil || keepNode(n) {
+			kept[i] = true
+			sg.MakeNodeOrdered(n.id, g.attrList(n.attrs))
 		}
-		bw.WriteString(fmt.Sprintf("%s ", n.id))
-		g.writeAttrs(bw, n.attrs, yesCommas, yesBrackets)
-		bw.WriteString("\n")
 	}
-
-	// Edges
-	for nid, n := range g.nodes {
-		if !emit(uint32(nid)) {
+	for i := range g.edges {
+		e := &g.edges[i]
+		if !kept[e.src] || !kept[e.sink] {
 			continue
 		}
-		for _, eid := range n.outadjlist {
-			e := g.edges[eid]
-			if !emit(uint32(e.sink)) {
-				continue
-			}
-			bw.WriteString(fmt.Sprintf("%s -> %s",
-				g.nodes[e.src].id, g.nodes[e.sink].id))
-			g.writeAttrs(bw, e.attrs, noCommas, yesBrackets)
-			bw.WriteString("\n")
-
+		if keepEdge == nil || keepEdge(uint32(i), e) {
+			sg.AddEdgeOrdered(g.nodes[e.src].id, g.nodes[e.sink].id,
+				g.attrList(e.attrs))
 		}
 	}
+	return sg
+}
 
-	bw.WriteString("}\n")
-	bw.Flush()
+func (g *Graph) GetEndpoints(e *Edge) (uint32, uint32) {
+	return e.src, e.sink
+}
+
+func (g *Graph) GetNodeIndex(n *Node) uint32 {
+	return n.idx
+}
+
+func (g *Graph) LookupNode(nid string) *Node {
+	if idx, ok := g.ntab[nid]; ok {
+		return &g.nodes[idx]
+	}
 	return nil
 }
+
+func (g *Graph) GetNodeCount() uint32 {
+	return uint32(len(g.nodes))
+}
+
+func (g *Graph) GetEdgeCount() uint32 {
+	return uint32(len(g.edges))
+}
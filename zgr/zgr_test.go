@@ -2,7 +2,12 @@ package zgr
 
 import (
 	"bufio"
+	"errors"
+	"fmt"
+	"iter"
+	"slices"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/thanm/grvutils/testutils"
@@ -112,3 +117,616 @@ splines=polyline
 		t.Errorf("write: want:\n%s\ngot:\n%s\n", want, got)
 	}
 }
+
+func TestIterators(t *testing.T) {
+	g := makeg()
+	var sb strings.Builder
+	for n := range g.Nodes() {
+		sb.WriteString(fmt.Sprintf("%s:", n.Label()))
+		for s := range g.Successors(n) {
+			sb.WriteString(fmt.Sprintf(" s=%s", s.Label()))
+		}
+		for p := range g.Predecessors(n) {
+			sb.WriteString(fmt.Sprintf(" p=%s", p.Label()))
+		}
+		for eid, e := range g.OutEdges(n) {
+			src, sink := g.GetEndpoints(e)
+			sb.WriteString(fmt.Sprintf(" o%d=%d,%d", eid, src, sink))
+		}
+		for eid := range g.InEdges(n) {
+			sb.WriteString(fmt.Sprintf(" i%d", eid))
+		}
+		sb.WriteString("\n")
+	}
+	exp := `a: s=b p=c o0=0,1 i2
+		b: s=c p=a p=c o1=1,2 i0 i3
+		c: s=a s=b p=b o2=2,0 o3=2,1 i1`
+	td := testutils.Check(sb.String(), exp)
+	if td != "" {
+		t.Errorf(td)
+	}
+
+	ne := 0
+	for eid, e := range g.Edges() {
+		src, sink := g.GetEndpoints(e)
+		if !g.HasEdge(src, sink) {
+			t.Errorf("HasEdge(%d,%d) false for edge %d", src, sink, eid)
+		}
+		if fe, ok := g.FindEdge(src, sink); !ok || fe != eid {
+			t.Errorf("FindEdge(%d,%d) got %d,%v want %d", src, sink, fe, ok, eid)
+		}
+		ne++
+	}
+	if ne != 4 {
+		t.Errorf("Edges() visited %d edges want 4", ne)
+	}
+	if g.HasEdge(0, 2) {
+		t.Errorf("HasEdge(0,2) unexpectedly true")
+	}
+	if _, ok := g.FindEdge(1, 0); ok {
+		t.Errorf("FindEdge(1,0) unexpectedly found")
+	}
+
+	// Early exit from an iterator.
+	count := 0
+	for range g.Nodes() {
+		count++
+		break
+	}
+	if count != 1 {
+		t.Errorf("break from Nodes() visited %d nodes", count)
+	}
+
+	tg := g.Transpose()
+	if !tg.HasEdge(1, 0) || tg.HasEdge(0, 1) {
+		t.Errorf("transposed graph edge lookup incorrect")
+	}
+}
+
+func TestWriteOrder(t *testing.T) {
+	g := NewGraph()
+	g.SetName("Foo")
+	g.SetStrict(true)
+	g.SetAttrsOrdered([]Attr{NewAttr("splines", "polyline"), NewAttr("rankdir", "LR")})
+	g.MakeNodeOrdered("z", []Attr{NewAttr("shape", "box"), NewAttr("label", "Z")})
+	g.MakeNodeOrdered("m", []Attr{NewAttr("label", "M")})
+	g.MakeNodeOrdered("a", nil)
+	g.AddEdgeOrdered("z", "m", []Attr{NewAttr("y", "1"), NewAttr("x", "2")})
+	g.AddEdgeOrdered("a", "z", nil)
+	g.AddEdgeOrdered("z", "a", nil)
+
+	var sb strings.Builder
+	if err := g.WriteWithOptions(&sb, nil, WriteOptions{Order: InputOrder}); err != nil {
+		t.Fatalf("writing: %v", err)
+	}
+	got := strings.TrimSpace(sb.String())
+	want := strings.TrimSpace(`strict digraph Foo {
+splines=polyline rankdir=LR
+z  [shape=box, label=Z]
+m  [label=M]
+a 
+z -> m [y=1 x=2]
+a -> z
+z -> a
+}`)
+	if got != want {
+		t.Errorf("input order: want:\n%s\ngot:\n%s\n", want, got)
+	}
+
+	sb.Reset()
+	if err := g.WriteWithOptions(&sb, nil, WriteOptions{Order: SortedOrder}); err != nil {
+		t.Fatalf("writing: %v", err)
+	}
+	got = strings.TrimSpace(sb.String())
+	want = strings.TrimSpace(`strict digraph Foo {
+rankdir=LR splines=polyline
+a 
+m  [label=M]
+z  [label=Z, shape=box]
+a -> z
+z -> a
+z -> m [x=2 y=1]
+}`)
+	if got != want {
+		t.Errorf("sorted order: want:\n%s\ngot:\n%s\n", want, got)
+	}
+
+	// The zero value keeps the order Write has always used.
+	sb.Reset()
+	if err := g.Write(&sb, nil); err != nil {
+		t.Fatalf("writing: %v", err)
+	}
+	got = strings.TrimSpace(sb.String())
+	want = strings.TrimSpace(`strict digraph Foo {
+rankdir=LR splines=polyline
+z  [label=Z, shape=box]
+m  [label=M]
+a 
+z -> m [x=2 y=1]
+z -> a
+a -> z
+}`)
+	if got != want {
+		t.Errorf("index order: want:\n%s\ngot:\n%s\n", want, got)
+	}
+
+	g.SetDirected(false)
+	sb.Reset()
+	if err := g.Write(&sb, map[uint32]bool{0: true, 1: true}); err != nil {
+		t.Fatalf("writing: %v", err)
+	}
+	if !strings.Contains(sb.String(), "strict graph Foo {") ||
+		!strings.Contains(sb.String(), "z -- m") {
+		t.Errorf("undirected write: got:\n%s\n", sb.String())
+	}
+}
+
+func TestWriteOptions(t *testing.T) {
+	g := NewGraph()
+	g.SetAttrsOrdered([]Attr{NewAttr("rankdir", "LR")})
+	g.SetNodeDefaults([]Attr{NewAttr("shape", "box")})
+	g.SetEdgeDefaults([]Attr{NewAttr("color", `"blue"`)})
+	g.MakeNodeOrdered(`"a"`, []Attr{NewAttr("label", `"A"`), NewAttr("fontname", `"Helvetica Bold"`)})
+	g.MakeNodeOrdered(`"b"`, []Attr{NewAttr("label", "B"), NewAttr("tooltip", `"node"`)})
+	g.AddEdgeOrdered(`"a"`, `"b"`, []Attr{NewAttr("weight", "2"), NewAttr("style", `"dashed"`)})
+
+	var sb strings.Builder
+	opts := WriteOptions{
+		Order:        InputOrder,
+		Indent:       "  ",
+		Semicolons:   true,
+		Commas:       AllCommas,
+		Quoting:      QuoteAll,
+		EmitDefaults: true,
+	}
+	if err := g.WriteWithOptions(&sb, nil, opts); err != nil {
+		t.Fatalf("writing: %v", err)
+	}
+	want := `digraph "G" {
+  rankdir="LR";
+  node [shape="box"];
+  edge [color="blue"];
+  "a"  [label="A", fontname="Helvetica Bold"];
+  "b"  [label="B", tooltip="node"];
+  "a" -> "b" [weight="2", style="dashed"];
+}
+`
+	if sb.String() != want {
+		t.Errorf("all options: want:\n%s\ngot:\n%s\n", want, sb.String())
+	}
+
+	sb.Reset()
+	opts = WriteOptions{
+		Order:     InputOrder,
+		Commas:    NoCommas,
+		Quoting:   QuoteMinimal,
+		WrapWidth: 24,
+	}
+	if err := g.WriteWithOptions(&sb, nil, opts); err != nil {
+		t.Fatalf("writing: %v", err)
+	}
+	want = `digraph G {
+rankdir=LR
+"a"  [label=A
+    fontname="Helvetica Bold"]
+"b"  [label=B
+    tooltip="node"]
+"a" -> "b" [weight=2
+    style=dashed]
+}
+`
+	if sb.String() != want {
+		t.Errorf("minimal/wrapped: want:\n%s\ngot:\n%s\n", want, sb.String())
+	}
+}
+
+func TestQuote(t *testing.T) {
+	cases := []struct{ in, want string }{
+		{`abc`, `"abc"`},
+		{`"abc"`, `"\"abc\""`},
+		{`say "hi"`, `"say \"hi\""`},
+		{`"x" and "y"`, `"\"x\" and \"y\""`},
+		{`back\"slash`, `"back\\\"slash"`},
+		{`line\lnext`, `"line\lnext"`},
+		{`trailing\`, `"trailing\\"`},
+		{`<init>`, `"<init>"`},
+	}
+	for _, c := range cases {
+		if got := Quote(c.in); got != c.want {
+			t.Errorf("Quote(%s) = %s, want %s", c.in, got, c.want)
+		}
+	}
+	for _, in := range []string{`abc`, `say "hi"`, `"hi"`, `"x" and "y"`,
+		`a\lb`, `a\\lb`, `trailing\`, `\\`, `\"`, `""`, `"`, ``} {
+		if got := Unquote(Quote(in)); got != in {
+			t.Errorf("Unquote(Quote(%s)) = %s", in, got)
+		}
+	}
+	if got := Unquote(`plain`); got != `plain` {
+		t.Errorf("Unquote(plain) = %s", got)
+	}
+	if got := Unquote(`"a\\b\"c\nd"`); got != `a\b"c\nd` {
+		t.Errorf("Unquote = %s", got)
+	}
+
+	rawCases := []struct{ in, want string }{
+		{`abc`, `"abc"`},
+		{`"abc"`, `"abc"`},
+		{`already \"escaped\"`, `"already \"escaped\""`},
+		{`trailing\`, `"trailing\\"`},
+	}
+	for _, c := range rawCases {
+		if got := QuoteRaw(c.in); got != c.want {
+			t.Errorf("QuoteRaw(%s) = %s, want %s", c.in, got, c.want)
+		}
+	}
+
+	// QuoteAll leaves HTML strings alone.
+	g := NewGraph()
+	g.MakeNodeOrdered(`"a"`, []Attr{NewAttr("label", `<<b>A</b>>`), NewAttr("tooltip", `x\"y`)})
+	var sb strings.Builder
+	if err := g.WriteWithOptions(&sb, nil, WriteOptions{Quoting: QuoteAll}); err != nil {
+		t.Fatalf("writing: %v", err)
+	}
+	want := `digraph "G" {
+"a"  [label=<<b>A</b>>, tooltip="x\"y"]
+}
+`
+	if sb.String() != want {
+		t.Errorf("QuoteAll: want:\n%s\ngot:\n%s\n", want, sb.String())
+	}
+}
+
+type failWriter struct {
+	left int
+}
+
+func (fw *failWriter) Write(p []byte) (int, error) {
+	if len(p) > fw.left {
+		n := fw.left
+		fw.left = 0
+		return n, errors.New("disk full")
+	}
+	fw.left -= len(p)
+	return len(p), nil
+}
+
+func TestWriteError(t *testing.T) {
+	g := makeg()
+	if err := g.Write(&failWriter{left: 10}, nil); err == nil {
+		t.Errorf("expected error from failing writer")
+	}
+	if err := g.Write(&failWriter{left: 1 << 20}, nil); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	og := makeg()
+	ng := NewGraph()
+	ng.MakeNode("1", map[string]string{"label": "a", "prop1": "3", "prop2": "zilch"})
+	ng.MakeNode("2", map[string]string{"label": "b", "prop1": "2", "prop2": "zilch"})
+	ng.MakeNode("4", map[string]string{"label": "d"})
+	ng.AddEdge("1", "2", map[string]string{"label": "", "prop1": "2", "prop2": "zilch"})
+	ng.AddEdge("2", "4", nil)
+	ng.AddEdge("4", "1", map[string]string{"color": "blue"})
+	ng.SetAttrs(map[string]string{"rankdir": "LR"})
+
+	d := og.Diff(ng)
+	if d.Empty() {
+		t.Fatalf("diff unexpectedly empty")
+	}
+	var sb strings.Builder
+	if err := d.Write(&sb); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	want := `~ graph: +rankdir=LR
+- node 3
++ node 4
+~ node 1: prop1: 2 -> 3
+- edge 2 -> 3
+- edge 3 -> 1
+- edge 3 -> 2
++ edge 2 -> 4
++ edge 4 -> 1
+`
+	if sb.String() != want {
+		t.Errorf("diff report: want:\n%s\ngot:\n%s\n", want, sb.String())
+	}
+
+	sb.Reset()
+	if err := d.Overlay().WriteWithOptions(&sb, nil, WriteOptions{Order: InputOrder}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	want = `digraph G {
+rankdir=LR
+1  [label=a, prop1=3, prop2=zilch]
+2  [label=b, prop1=2, prop2=zilch]
+4  [label=d, color=green]
+3  [label=c, prop1=2, prop2=zilch, color=red]
+1 -> 2 [label= prop1=2 prop2=zilch]
+2 -> 4 [color=green]
+4 -> 1 [color=green]
+2 -> 3 [label= prop1=2 prop2=zilch color=red]
+3 -> 1 [label= prop1=2 prop2=zilch color=red]
+3 -> 2 [label= prop1=2 prop2=zilch color=red]
+}
+`
+	if sb.String() != want {
+		t.Errorf("overlay: want:\n%s\ngot:\n%s\n", want, sb.String())
+	}
+
+	if d := og.Diff(makeg()); !d.Empty() {
+		t.Errorf("diff of identical graphs not empty: %+v", d)
+	}
+
+	// Empty values are told apart from missing ones.
+	eg1, eg2 := NewGraph(), NewGraph()
+	eg1.MakeNode("1", map[string]string{"a": "", "b": "x"})
+	eg2.MakeNode("1", map[string]string{"b": "", "c": ""})
+	sb.Reset()
+	if err := eg1.Diff(eg2).Write(&sb); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if want := "~ node 1: -a=; b: x -> ; +c=\n"; sb.String() != want {
+		t.Errorf("empty values: want %q, got %q", want, sb.String())
+	}
+}
+
+func TestMerge(t *testing.T) {
+	g1 := NewGraph()
+	g1.SetName("P1")
+	g1.MakeNodeOrdered(`"a"`, []Attr{NewAttr("label", `"A"`), NewAttr("color", "red")})
+	g1.MakeNodeOrdered(`"b"`, nil)
+	g1.AddEdgeOrdered(`"a"`, `"b"`, []Attr{NewAttr("w", "1")})
+	g2 := NewGraph()
+	g2.SetName("P2")
+	g2.MakeNodeOrdered(`"c"`, nil)
+	g2.MakeNodeOrdered(`"a"`, []Attr{NewAttr("color", "blue"), NewAttr("shape", "box")})
+	g2.MakeNodeOrdered(`"b"`, nil)
+	g2.AddEdgeOrdered(`"b"`, `"c"`, nil)
+	g2.AddEdgeOrdered(`"a"`, `"b"`, []Attr{NewAttr("w", "1")})
+
+	write := func(g *Graph) string {
+		var sb strings.Builder
+		if err := g.WriteWithOptions(&sb, nil, WriteOptions{Order: InputOrder}); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		return sb.String()
+	}
+	tests := []struct {
+		policy ConflictPolicy
+		color  string
+	}{
+		{FirstWins, "red"},
+		{LastWins, "blue"},
+		{Concatenate, `"red;blue"`},
+	}
+	for _, tc := range tests {
+		mg, err := Merge(MergeOptions{Policy: tc.policy, Sep: ";"}, g1, g2)
+		if err != nil {
+			t.Fatalf("policy %d: %v", tc.policy, err)
+		}
+		want := fmt.Sprintf(`digraph P1 {
+"a"  [label="A", color=%s, shape=box]
+"b" 
+"c" 
+"a" -> "b" [w=1]
+"b" -> "c"
+}
+`, tc.color)
+		if got := write(mg); got != want {
+			t.Errorf("policy %d: want:\n%s\ngot:\n%s\n", tc.policy, want, got)
+		}
+	}
+	if _, err := Merge(MergeOptions{Policy: ErrorOnConflict}, g1, g2); err == nil {
+		t.Errorf("expected conflict error")
+	}
+	if _, err := Merge(MergeOptions{Policy: ErrorOnConflict}, g1, g1); err != nil {
+		t.Errorf("unexpected error merging graph with itself: %v", err)
+	}
+}
+
+func TestBinary(t *testing.T) {
+	g := makeg()
+	g.SetName(`"bin"`)
+	g.SetStrict(true)
+	g.SetAttrsOrdered([]Attr{NewAttr("rankdir", "LR")})
+	g.SetNodeDefaults([]Attr{NewAttr("shape", "box")})
+	g.SetNodeAttr(g.GetNode(0), "label", `"relabeled"`)
+
+	data, err := g.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ng := NewGraph()
+	if err := ng.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	write := func(g *Graph) string {
+		var sb strings.Builder
+		opts := WriteOptions{Order: InputOrder, EmitDefaults: true}
+		if err := g.WriteWithOptions(&sb, nil, opts); err != nil {
+			t.Fatal(err)
+		}
+		return sb.String()
+	}
+	if td := testutils.Check(write(ng), write(g)); td != "" {
+		t.Errorf(td)
+	}
+	if ng.GetNode(0).Label() != `"relabeled"` || !ng.Strict() {
+		t.Errorf("label or kind not restored")
+	}
+	if d := g.Diff(ng); !d.Empty() {
+		t.Errorf("decoded graph differs from original")
+	}
+	// The decoded graph must be usable for further updates.
+	if err := ng.AddEdgeOrdered(g.GetNode(2).Id(), g.GetNode(2).Id(), nil); err != nil {
+		t.Errorf("AddEdge on decoded graph: %v", err)
+	}
+
+	bad := [][]byte{
+		nil,
+		[]byte("XXXX"),
+		data[:len(data)-1],
+		append(append([]byte{}, data...), 0),
+		append([]byte("ZGRB"), 9),
+	}
+	for i, b := range bad {
+		if err := NewGraph().UnmarshalBinary(b); err == nil {
+			t.Errorf("bad input %d: expected error", i)
+		}
+	}
+}
+
+func TestFreeze(t *testing.T) {
+	g := makeg()
+	g.SetAttrsOrdered([]Attr{NewAttr("rankdir", "LR")})
+	g.SetNodeAttr(g.GetNode(1), "color", "red")
+	f := g.Freeze()
+
+	if f.GetNodeCount() != g.GetNodeCount() || f.GetEdgeCount() != 4 {
+		t.Fatalf("counts: %d nodes %d edges", f.GetNodeCount(), f.GetEdgeCount())
+	}
+	for n := range g.Nodes() {
+		idx := n.Idx()
+		if f.NodeID(idx) != n.Id() {
+			t.Errorf("NodeID(%d) = %s, want %s", idx, f.NodeID(idx), n.Id())
+		}
+		if fi, ok := f.LookupIndex(n.Id()); !ok || fi != idx {
+			t.Errorf("LookupIndex(%s) = %d, %v", n.Id(), fi, ok)
+		}
+		got := fmt.Sprint(f.GetNodeAttrsOrdered(idx))
+		if want := fmt.Sprint(g.GetNodeAttrsOrdered(n)); got != want {
+			t.Errorf("node %d attrs: got %s want %s", idx, got, want)
+		}
+		for _, pair := range [][2]func(uint32) iter.Seq[uint32]{
+			{f.SuccessorIndices, g.SuccessorIndices},
+			{f.PredecessorIndices, g.PredecessorIndices},
+		} {
+			got := slices.Collect(pair[0](idx))
+			if want := slices.Collect(pair[1](idx)); !slices.Equal(got, want) {
+				t.Errorf("node %d neighbors: got %v want %v", idx, got, want)
+			}
+		}
+	}
+	if _, ok := f.LookupIndex("4"); ok {
+		t.Errorf("LookupIndex found nonexistent node")
+	}
+	if eid, ok := f.FindEdge(2, 1); !ok || eid != 3 {
+		t.Errorf("FindEdge(2, 1) = %d, %v", eid, ok)
+	}
+	if _, ok := f.FindEdge(0, 2); ok {
+		t.Errorf("FindEdge found nonexistent edge")
+	}
+	for _, key := range []string{"prop1", "label", "color"} {
+		gv, gok := g.EdgeAttr(3, key)
+		fv, fok := f.EdgeAttr(3, key)
+		if gv != fv || gok != fok {
+			t.Errorf("EdgeAttr(3, %s): frozen %q,%v graph %q,%v", key, fv, fok, gv, gok)
+		}
+	}
+	if v, ok := f.EdgeAttr(3, "prop1"); !ok || v != "2" {
+		t.Errorf("EdgeAttr(3, prop1) = %q, %v", v, ok)
+	}
+	for idx := uint32(0); idx < f.GetNodeCount(); idx++ {
+		var gout, fout, gin, fin []uint32
+		for e, w := range g.OutEdgeIndices(idx) {
+			gout = append(gout, e, w)
+		}
+		for e, w := range f.OutEdgeIndices(idx) {
+			fout = append(fout, e, w)
+		}
+		for e, w := range g.InEdgeIndices(idx) {
+			gin = append(gin, e, w)
+		}
+		for e, w := range f.InEdgeIndices(idx) {
+			fin = append(fin, e, w)
+		}
+		if fmt.Sprint(gout, gin) != fmt.Sprint(fout, fin) {
+			t.Errorf("node %d edge indices: frozen %v %v graph %v %v", idx, fout, fin, gout, gin)
+		}
+	}
+
+	// A nil include selects every node, for both forms.
+	if d := g.Subgraph(nil).Diff(f.Subgraph(nil)); !d.Empty() {
+		t.Errorf("Subgraph(nil) differs between graph and frozen copy")
+	}
+
+	// Changes to g after freezing don't show through.
+	g.SetNodeAttr(g.GetNode(0), "label", "changed")
+	if d := makeg().Diff(f.Subgraph(nil)); len(d.ChangedNodes) != 1 || len(d.GraphAttrs) != 1 {
+		t.Errorf("unexpected diff between frozen copy and original")
+	}
+
+	sg := f.Subgraph(map[uint32]bool{1: true, 2: true})
+	var sb strings.Builder
+	if err := sg.WriteWithOptions(&sb, nil, WriteOptions{Order: InputOrder}); err != nil {
+		t.Fatal(err)
+	}
+	want := `digraph G {
+  rankdir=LR
+2  [label=b, prop1=2, prop2=zilch, color=red]
+3  [label=c, prop1=2, prop2=zilch]
+2 -> 3 [label= prop1=2 prop2=zilch]
+3 -> 2 [label= prop1=2 prop2=zilch]
+}
+`
+	if td := testutils.Check(sb.String(), want); td != "" {
+		t.Errorf(td)
+	}
+}
+
+// TestConcurrentReads exercises the read APIs of Graph and Frozen from
+// several goroutines at once; it is mainly useful under -race.
+func TestConcurrentReads(t *testing.T) {
+	g := makeg()
+	g.SetNodeDefaults([]Attr{NewAttr("shape", "box")})
+	f := g.Freeze()
+	write := func(w func(*strings.Builder) error) string {
+		var sb strings.Builder
+		if err := w(&sb); err != nil {
+			t.Error(err)
+		}
+		return sb.String()
+	}
+	opts := WriteOptions{EmitDefaults: true}
+	wantg := write(func(sb *strings.Builder) error { return g.WriteWithOptions(sb, nil, opts) })
+	wantf := write(func(sb *strings.Builder) error { return f.WriteWithOptions(sb, nil, opts) })
+	if wantg != wantf {
+		t.Fatalf("Frozen.Write differs from Graph.Write:\n%s\nvs\n%s", wantf, wantg)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				for _, top := range []Topology{g, f} {
+					for idx := uint32(0); idx < top.GetNodeCount(); idx++ {
+						if k, ok := top.LookupIndex(top.NodeID(idx)); !ok || k != idx {
+							t.Errorf("lookup of node %d failed", idx)
+						}
+						for range top.SuccessorIndices(idx) {
+						}
+						for range top.PredecessorIndices(idx) {
+						}
+					}
+				}
+				for n := range g.Nodes() {
+					g.GetNodeAttrs(n)
+					f.GetNodeAttrsOrdered(n.Idx())
+				}
+				if s := write(func(sb *strings.Builder) error { return f.WriteWithOptions(sb, nil, opts) }); s != wantf {
+					t.Errorf("concurrent Frozen.Write produced different output")
+				}
+				if s := write(func(sb *strings.Builder) error { return g.WriteWithOptions(sb, nil, opts) }); s != wantg {
+					t.Errorf("concurrent Graph.Write produced different output")
+				}
+				f.Subgraph(map[uint32]bool{0: true, 1: true})
+			}
+		}()
+	}
+	wg.Wait()
+}